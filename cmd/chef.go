@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"github.com/spf13/viper"
+	"sourcesign.de/cloudprism/common"
+)
+
+// Returns a chef for the selected environment, with the recipes and state store from the config file.
+func configChef() (common.Chef, error) {
+	env, err := selectedEnvironment()
+	if err != nil {
+		return nil, err
+	}
+
+	return configEnvironmentChef(env)
+}
+
+// Returns a chef for an environment, with the recipes and state store from the config file.
+func configEnvironmentChef(env common.ApplicationEnvironment) (common.Chef, error) {
+	app, err := configApplication()
+	if err != nil {
+		return nil, err
+	}
+
+	recipes, err := configEnvironmentRecipes(env)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := configStateStore(env)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := configApplicationMetadata()
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := configStandardTags(env, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	policies := map[string]common.ResourcePolicyConfig{}
+	if err := unmarshalConfigKey("resourcePolicies", &policies); err != nil {
+		return nil, err
+	}
+
+	policy, err := common.GetResourcePolicy(env, policies, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	retryCfg := common.RetryPolicyConfig{}
+	if err := unmarshalConfigKey("retry", &retryCfg); err != nil {
+		return nil, err
+	}
+
+	retry, err := common.GetRetryPolicy(retryCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := configStackOutputReader()
+	if err != nil {
+		return nil, err
+	}
+
+	programOptions := []common.ProgramOption{
+		common.WithStandardTags(tags),
+		common.WithNaming(common.GetNaming(app, env, configRegion(env))),
+		common.WithResourcePolicy(policy),
+		common.WithStackOutputReader(reader),
+	}
+
+	if metadata != nil {
+		programOptions = append(programOptions, common.WithApplicationMetadata(*metadata))
+	}
+
+	chef, err := common.GetDefaultChef(app, env, store,
+		common.WithRetryPolicy(retry),
+		common.WithDeploymentTarget(configDeploymentTarget()),
+		common.WithProgramOptions(programOptions...))
+	if err != nil {
+		return nil, err
+	}
+
+	chef.Append(recipes...)
+
+	return chef, nil
+}
+
+// Returns the validated application metadata of the "metadata" section of the config file, nil if there is none.
+func configApplicationMetadata() (*common.ApplicationMetadata, error) {
+	if !viper.IsSet("metadata") {
+		return nil, nil
+	}
+
+	metadata := common.ApplicationMetadata{}
+	if err := unmarshalConfigKey("metadata", &metadata); err != nil {
+		return nil, err
+	}
+
+	if err := metadata.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &metadata, nil
+}
+
+// Returns the region of the selected environment, the one of the config file if the environment has none.
+func configRegion(env common.ApplicationEnvironment) string {
+	if region := env.Region(); region != "" {
+		return region
+	}
+
+	return viper.GetString("region")
+}
+
+// Returns the standard tags of an environment, from the "tags" section of the config file, the application
+// metadata if any, and the Git revision of the directory of the config file. The tags section takes precedence.
+func configStandardTags(env common.ApplicationEnvironment, metadata *common.ApplicationMetadata) (map[string]string, error) {
+	cfg := common.TagsConfig{}
+
+	if err := unmarshalConfigKey("tags", &cfg); err != nil {
+		return nil, err
+	}
+
+	app, err := configApplication()
+	if err != nil {
+		return nil, err
+	}
+
+	if metadata != nil {
+		extra := metadata.Tags()
+		for key, value := range cfg.Extra {
+			extra[key] = value
+		}
+
+		cfg.Extra = extra
+
+		if cfg.Owner == "" {
+			cfg.Owner = metadata.OwnerTeam
+		}
+
+		if cfg.CostCenter == "" {
+			cfg.CostCenter = metadata.CostCenter
+		}
+	}
+
+	return common.GetStandardTags(cfg, app, env, configDeploymentTarget(), appVersion+"+"+Revision), nil
+}
+
+// Returns the deployment target of the directory of the config file, its Git hash if the working tree is clean.
+func configDeploymentTarget() string {
+	return common.GetDeploymentTargetName(configDir())
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+	"sourcesign.de/cloudprism/common"
+)
+
+// nolint: gochecknoglobals
+// configCmd groups the commands inspecting the config file.
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the config file",
+}
+
+// nolint: gochecknoglobals
+// configEffectiveCmd prints the recipes as they apply to the selected environment.
+var configEffectiveCmd = &cobra.Command{
+	Use:   "effective",
+	Short: "Print the application metadata and the recipes as they apply to the selected environment",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		env, err := selectedEnvironment()
+		if err != nil {
+			return err
+		}
+
+		if viper.ConfigFileUsed() == "" {
+			return fmt.Errorf("no config file found")
+		}
+
+		cfgs, err := common.LoadRecipeConfigs(viper.ConfigFileUsed())
+		if err != nil {
+			return err
+		}
+
+		if _, err := common.GetRecipes(cfgs, env); err != nil {
+			return err
+		}
+
+		metadata, err := configApplicationMetadata()
+		if err != nil {
+			return err
+		}
+
+		effective := struct {
+			Environment string                      `yaml:"environment"`
+			Metadata    *common.ApplicationMetadata `yaml:"metadata,omitempty"`
+			Recipes     []common.RecipeConfig       `yaml:"recipes"`
+		}{
+			Environment: env.ID(),
+			Metadata:    metadata,
+			Recipes:     common.ResolveRecipeConfigs(cfgs, env),
+		}
+
+		out, err := yaml.Marshal(effective)
+		if err != nil {
+			return err
+		}
+
+		fmt.Print(string(out))
+
+		return nil
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configEffectiveCmd)
+	rootCmd.AddCommand(configCmd)
+}
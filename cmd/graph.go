@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"sourcesign.de/cloudprism/common"
+)
+
+// nolint: gochecknoglobals
+var (
+	graphFormat  string
+	graphPreview bool
+)
+
+// nolint: gochecknoglobals
+// graphCmd groups the commands exporting graphs.
+var graphCmd = &cobra.Command{
+	Use:   "graph",
+	Short: "Export the ingredient or resource graph as Graphviz DOT or Mermaid",
+}
+
+// nolint: gochecknoglobals
+// graphRecipesCmd exports the ingredient dependency graph of the configured recipes.
+var graphRecipesCmd = &cobra.Command{
+	Use:   "recipes",
+	Short: "Export the ingredient dependency graph of the recipes in the config file",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		recipes, err := configRecipes()
+		if err != nil {
+			return err
+		}
+
+		return printGraph(common.GetRecipeGraph(recipes))
+	},
+}
+
+// nolint: gochecknoglobals
+// graphStateCmd exports the resource graph of the stack of the selected environment.
+var graphStateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "Export the resource graph of the stack, optionally highlighting the changes of a preview",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		chef, err := configChef()
+		if err != nil {
+			return err
+		}
+
+		graph, err := chef.Graph(graphPreview)
+		if err != nil {
+			return err
+		}
+
+		return printGraph(graph)
+	},
+}
+
+func init() {
+	graphCmd.PersistentFlags().StringVar(&graphFormat, "format", "dot", "output format: dot or mermaid")
+	graphStateCmd.Flags().BoolVar(&graphPreview, "preview", false, "run a preview and highlight the resources it would change")
+
+	graphCmd.AddCommand(graphRecipesCmd)
+	graphCmd.AddCommand(graphStateCmd)
+	rootCmd.AddCommand(graphCmd)
+}
+
+// Prints a graph in the selected format.
+func printGraph(graph common.Graph) error {
+	switch graphFormat {
+	case "dot":
+		fmt.Print(graph.DOT())
+	case "mermaid":
+		fmt.Print(graph.Mermaid())
+	default:
+		return fmt.Errorf("unknown format %q, expected dot or mermaid", graphFormat)
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"sourcesign.de/cloudprism/common"
+)
+
+// nolint: gochecknoglobals
+var importMappingFile string
+
+// nolint: gochecknoglobals
+// importCmd adopts existing cloud resources into the stack of the selected environment.
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Adopt existing cloud resources into the ingredients of the stack",
+	Long: "Adopt existing cloud resources, e.g. created by hand, into the ingredients of the stack.\n" +
+		"The mapping file maps ingredient names to resource IDs, or to resource names mapped to resource IDs\n" +
+		"for ingredients creating several resources. Only the mapped resources are updated, a preview of the\n" +
+		"whole stack then confirms that no resource would be replaced.",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mapping, err := common.LoadImportMapping(importMappingFile)
+		if err != nil {
+			return err
+		}
+
+		chef, err := configChef()
+		if err != nil {
+			return err
+		}
+
+		return chef.Import(mapping)
+	},
+}
+
+func init() {
+	importCmd.Flags().StringVar(&importMappingFile, "mapping", "imports.yaml", "file mapping ingredient names to the IDs of the resources to import")
+
+	rootCmd.AddCommand(importCmd)
+}
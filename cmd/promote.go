@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"sourcesign.de/cloudprism/common"
+)
+
+// nolint: gochecknoglobals
+var (
+	promoteFrom = &common.EnvironmentFlag{}
+	promoteTo   = &common.EnvironmentFlag{}
+)
+
+// nolint: gochecknoglobals
+// promoteCmd deploys the deployment target live in one environment to another one.
+var promoteCmd = &cobra.Command{
+	Use:   "promote",
+	Short: "Deploy the Git hash live in one environment to another one",
+	Long: "Deploy exactly the deployment target, i.e. the Git hash, currently live in the source environment to\n" +
+		"the target environment. The working tree must be clean and at that hash, and the last update of the\n" +
+		"source environment must have succeeded. The hash and the environments it was promoted through are\n" +
+		"recorded in the update message, and mirrored into the \"cloudprism:target\" and \"cloudprism:promotion\"\n" +
+		"stack tags, e.g. \"dev>int>prd\", where the state store keeps stack tags.",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		from, err := configEnvironment(promoteFrom)
+		if err != nil {
+			return err
+		}
+
+		to, err := configEnvironment(promoteTo)
+		if err != nil {
+			return err
+		}
+
+		if from == to {
+			return fmt.Errorf("--from and --to must differ")
+		}
+
+		source, err := configStateStore(from)
+		if err != nil {
+			return err
+		}
+
+		chef, err := configEnvironmentChef(to)
+		if err != nil {
+			return err
+		}
+
+		return chef.Promote(from, source)
+	},
+}
+
+func init() {
+	promoteCmd.Flags().Var(promoteFrom, "from", "environment to promote from, e.g. dev")
+	promoteCmd.Flags().Var(promoteTo, "to", "environment to promote to, e.g. int")
+	cobra.CheckErr(promoteCmd.MarkFlagRequired("from"))
+	cobra.CheckErr(promoteCmd.MarkFlagRequired("to"))
+
+	rootCmd.AddCommand(promoteCmd)
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"sourcesign.de/cloudprism/common"
+)
+
+// nolint: gochecknoglobals
+// recipesCmd groups the commands working on the recipe registry.
+var recipesCmd = &cobra.Command{
+	Use:   "recipes",
+	Short: "Inspect the registered recipes",
+}
+
+// nolint: gochecknoglobals
+// recipesListCmd lists all registered recipes.
+var recipesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all registered recipes",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+		fmt.Fprintln(writer, "NAME\tDESCRIPTION")
+
+		for _, recipe := range common.RegisteredRecipes() {
+			fmt.Fprintf(writer, "%s\t%s\n", recipe.Name, recipe.Description)
+		}
+
+		return writer.Flush()
+	},
+}
+
+// nolint: gochecknoglobals
+var (
+	recipesDescribeFormat string
+	recipesDescribeParams map[string]string
+)
+
+// nolint: gochecknoglobals
+// recipesDescribeCmd prints the documentation of a registered recipe, generated from the recipe itself.
+var recipesDescribeCmd = &cobra.Command{
+	Use:   "describe <name>",
+	Short: "Describe the ingredients, inputs, outputs and dependencies of a registered recipe",
+	Long: "Describe the ingredients, inputs, outputs and dependencies of a registered recipe.\n" +
+		"The description is generated from an instance of the recipe, created with the given parameters.",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		params := make(map[string]interface{}, len(recipesDescribeParams))
+		for key, value := range recipesDescribeParams {
+			params[key] = value
+		}
+
+		description, err := common.DescribeRecipe(args[0], params)
+		if err != nil {
+			return err
+		}
+
+		switch recipesDescribeFormat {
+		case "markdown":
+			fmt.Print(description.Markdown())
+		case "json":
+			encoded, err := description.JSON()
+			if err != nil {
+				return err
+			}
+
+			fmt.Print(encoded)
+		default:
+			return fmt.Errorf("unknown format %q, expected markdown or json", recipesDescribeFormat)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	recipesDescribeCmd.Flags().StringVar(&recipesDescribeFormat, "format", "markdown", "output format: markdown or json")
+	recipesDescribeCmd.Flags().StringToStringVar(&recipesDescribeParams, "param", nil, "parameter to create the recipe with, as key=value")
+
+	recipesCmd.AddCommand(recipesListCmd)
+	recipesCmd.AddCommand(recipesDescribeCmd)
+	rootCmd.AddCommand(recipesCmd)
+}
+
+// Returns the recipes configured in the "recipes" section of the config file, for the selected environment.
+func configRecipes() ([]common.Recipe, error) {
+	env, err := selectedEnvironment()
+	if err != nil {
+		return nil, err
+	}
+
+	return configEnvironmentRecipes(env)
+}
+
+// Returns the recipes configured in the "recipes" section of the config file, for an environment.
+func configEnvironmentRecipes(env common.ApplicationEnvironment) ([]common.Recipe, error) {
+	if viper.ConfigFileUsed() == "" {
+		return []common.Recipe{}, nil
+	}
+
+	cfgs, err := common.LoadRecipeConfigs(viper.ConfigFileUsed())
+	if err != nil {
+		return nil, err
+	}
+
+	return common.GetRecipes(cfgs, env)
+}
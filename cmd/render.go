@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"sourcesign.de/cloudprism/common"
+)
+
+// nolint: gochecknoglobals
+// renderCmd renders the templates of the config file with the outputs of the stack of the selected environment.
+var renderCmd = &cobra.Command{
+	Use:   "render",
+	Short: "Render the templates of the config file with the outputs of the stack",
+	Long: "Render the Go text/templates listed in the \"templates\" section of the config file, e.g. application\n" +
+		"configs or Helm values, with the outputs of the stack, and write them. Rendering fails if a template\n" +
+		"references a missing output. Files containing secret outputs are only readable by their owner.",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		templates, err := configTemplates()
+		if err != nil {
+			return err
+		}
+
+		if len(templates) == 0 {
+			return fmt.Errorf("no templates configured")
+		}
+
+		chef, err := configChef()
+		if err != nil {
+			return err
+		}
+
+		return chef.Render(templates)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(renderCmd)
+}
+
+// Returns the templates configured in the "templates" section of the config file, with relative paths resolved
+// against the directory of the config file.
+func configTemplates() ([]common.TemplateConfig, error) {
+	templates := []common.TemplateConfig{}
+	if err := unmarshalConfigKey("templates", &templates); err != nil {
+		return nil, err
+	}
+
+	dir := configDir()
+
+	for i, template := range templates {
+		if template.Template != "" && !filepath.IsAbs(template.Template) {
+			templates[i].Template = filepath.Join(dir, template.Template)
+		}
+
+		if template.Output != "" && !filepath.IsAbs(template.Output) {
+			templates[i].Output = filepath.Join(dir, template.Output)
+		}
+	}
+
+	return templates, nil
+}
@@ -2,13 +2,15 @@ package cmd
 
 import (
 	"os"
+	"path/filepath"
 	"runtime/debug"
 
-	"github.com/SourceSign/CloudPrism/common"
 	"github.com/apex/log"
 	figure "github.com/common-nighthawk/go-figure"
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"sourcesign.de/cloudprism/common"
 )
 
 const (
@@ -18,8 +20,10 @@ const (
 
 // nolint: gochecknoglobals
 var (
-	appConfigFile string
-	appDebug      bool
+	appConfigFile  string
+	appDebug       bool
+	appEnvironment = common.GetEnvironmentFlag(common.AppEnvDevelopment)
+	appReadOnly    bool
 
 	Revision = func() string {
 		if info, ok := debug.ReadBuildInfo(); ok {
@@ -60,6 +64,8 @@ func init() {
 
 	rootCmd.PersistentFlags().BoolVarP(&appDebug, "debug", "d", false, "Enable verbose logging")
 	rootCmd.PersistentFlags().StringVarP(&appConfigFile, "config", "f", "rapid.yaml", "config file to use")
+	rootCmd.PersistentFlags().VarP(appEnvironment, "env", "e", "application environment to work on")
+	rootCmd.PersistentFlags().BoolVar(&appReadOnly, "read-only", false, "refuse all operations modifying the state store")
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -85,6 +91,50 @@ func initConfig() {
 	}
 }
 
+// Returns the directory of the config file, the current directory if there is none.
+func configDir() string {
+	if file := viper.ConfigFileUsed(); file != "" {
+		return filepath.Dir(file)
+	}
+
+	return "."
+}
+
+// Returns the application configured in the config file.
+func configApplication() (common.Application, error) {
+	return common.ParseApplication(viper.GetString("application"))
+}
+
+// Returns the application environment selected with the --env flag, from the environments of the config file.
+func selectedEnvironment() (common.ApplicationEnvironment, error) {
+	return configEnvironment(appEnvironment)
+}
+
+// Returns the application environment of a flag, from the environments of the config file.
+// The environments are only known once the config file is read, so flags resolve environments late.
+func configEnvironment(flag *common.EnvironmentFlag) (common.ApplicationEnvironment, error) {
+	cfgs := []common.EnvironmentConfig{}
+	if err := unmarshalConfigKey("environments", &cfgs); err != nil {
+		return 0, err
+	}
+
+	if err := common.ConfigureEnvironments(cfgs); err != nil {
+		return 0, err
+	}
+
+	return flag.Environment()
+}
+
+// Unmarshals a section of the config file. Types implementing encoding.TextUnmarshaler, e.g.
+// common.ApplicationEnvironment, are read from text.
+func unmarshalConfigKey(key string, out interface{}) error {
+	return viper.UnmarshalKey(key, out, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		mapstructure.TextUnmarshallerHookFunc(),
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+	)))
+}
+
 // a fancy global ascii-art banner.
 func banner() string {
 	ascii := figure.NewFigure(appName, "chunky", true).String()
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"github.com/apex/log"
+	"github.com/spf13/cobra"
+	"sourcesign.de/cloudprism/common"
+)
+
+// nolint: gochecknoglobals
+var rotateSecretsConfig common.SecretsConfig
+
+// nolint: gochecknoglobals
+// secretsCmd groups the commands working on the secrets provider of the state store.
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Manage the secrets provider of the state store",
+}
+
+// nolint: gochecknoglobals
+// secretsRotateCmd re-encrypts all stacks of the state store with a new secrets provider.
+var secretsRotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Change the secrets provider or passphrase for every stack in the state store",
+	Long: "Change the secrets provider or passphrase for every stack in the state store.\n" +
+		"Stacks already using the new secrets provider are skipped, so an interrupted rotation can be run again.\n" +
+		"Rotation refuses to start if a Pulumi.<stack>.yaml next to the config file holds encrypted values.\n" +
+		"Update the \"secrets\" section of the config file afterwards.",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		newProvider, err := common.GetSecretsProvider(rotateSecretsConfig)
+		if err != nil {
+			return err
+		}
+
+		env, err := selectedEnvironment()
+		if err != nil {
+			return err
+		}
+
+		store, err := configStateStore(env)
+		if err != nil {
+			return err
+		}
+
+		if err := common.RotateSecretsProvider(store, newProvider, configDir()); err != nil {
+			return err
+		}
+
+		log.WithField("provider", newProvider.URL()).Info("Secrets provider rotated, update the config file to use it")
+
+		return nil
+	},
+}
+
+func init() {
+	secretsRotateCmd.Flags().StringVar(&rotateSecretsConfig.Provider, "provider", "passphrase", "new secrets provider: passphrase, awskms://... or hashivault://...")
+	secretsRotateCmd.Flags().StringVar(&rotateSecretsConfig.PassphraseFile, "passphrase-file", "", "read the new passphrase from this file")
+	secretsRotateCmd.Flags().StringVar(&rotateSecretsConfig.PassphraseEnv, "passphrase-env", "", "read the new passphrase from this environment variable")
+	secretsRotateCmd.Flags().StringVar(&rotateSecretsConfig.PassphraseCommand, "passphrase-command", "", "read the new passphrase from the output of this command")
+
+	secretsCmd.AddCommand(secretsRotateCmd)
+	rootCmd.AddCommand(secretsCmd)
+}
+
+// Returns the secrets provider configured in the "secrets" section of the config file.
+func configSecretsProvider() (common.SecretsProvider, error) {
+	cfg := common.SecretsConfig{}
+
+	if err := unmarshalConfigKey("secrets", &cfg); err != nil {
+		return nil, err
+	}
+
+	return common.GetSecretsProvider(cfg)
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"sourcesign.de/cloudprism/common"
+)
+
+// Returns the state store configured in the "stateStore" section of the config file, for an environment.
+func configStateStore(env common.ApplicationEnvironment) (common.StateStore, error) {
+	secretsProvider, err := configSecretsProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := common.StateStoreConfig{}
+
+	if err := unmarshalConfigKey("stateStore", &cfg); err != nil {
+		return nil, err
+	}
+
+	opts := []common.StateStoreOption{common.WithSecretsProvider(secretsProvider)}
+	if appReadOnly {
+		opts = append(opts, common.WithReadOnly())
+	}
+
+	app, err := configApplication()
+	if err != nil {
+		return nil, err
+	}
+
+	return common.GetStateStore(cfg, app, env, opts...)
+}
+
+// Returns a reader of the outputs of other application environments, each read from its own state store configured
+// in the "stateStore" section of the config file.
+func configStackOutputReader() (common.StackOutputReader, error) {
+	secretsProvider, err := configSecretsProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := common.StateStoreConfig{}
+
+	if err := unmarshalConfigKey("stateStore", &cfg); err != nil {
+		return nil, err
+	}
+
+	return common.GetStackOutputReader(cfg, common.WithSecretsProvider(secretsProvider)), nil
+}
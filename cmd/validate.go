@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/apex/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"sourcesign.de/cloudprism/common"
+)
+
+// nolint: gochecknoglobals
+// validateCmd validates all recipes of the config file offline, without opening any state store.
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate the recipes, ingredient inputs and application metadata of the config file",
+	Long: "Validate the recipes and ingredient inputs of the config file against the published JSON Schemas,\n" +
+		"and the application metadata against its required fields.\n" +
+		"No state store is opened, all problems found are reported at once.",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := configValidateRecipes()
+
+		if _, metadataErr := configApplicationMetadata(); metadataErr != nil {
+			err = joinConfigErrors(err, metadataErr)
+		}
+
+		if err == nil {
+			log.Info("All recipes and the application metadata are valid")
+
+			return nil
+		}
+
+		problems := common.ConfigErrors{}
+		if !errors.As(err, &problems) {
+			return err
+		}
+
+		for _, problem := range problems {
+			fmt.Println(problem.Error())
+		}
+
+		return fmt.Errorf("found %d problem(s)", len(problems))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+}
+
+// Validates the recipes of the config file for the selected environment, reporting the problems creating them
+// along with the ones of the recipes that could be created.
+func configValidateRecipes() error {
+	env, err := selectedEnvironment()
+	if err != nil {
+		return err
+	}
+
+	if viper.ConfigFileUsed() == "" {
+		return nil
+	}
+
+	cfgs, err := common.LoadRecipeConfigs(viper.ConfigFileUsed())
+	if err != nil {
+		return err
+	}
+
+	return common.ValidateRecipeConfigs(cfgs, env)
+}
+
+// Joins two errors, keeping ConfigErrors reportable one by one if both are.
+func joinConfigErrors(err, other error) error {
+	if err == nil {
+		return other
+	}
+
+	problems, otherProblems := common.ConfigErrors{}, common.ConfigErrors{}
+	if errors.As(err, &problems) && errors.As(other, &otherProblems) {
+		return append(problems, otherProblems...)
+	}
+
+	return errors.Join(err, other)
+}
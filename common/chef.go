@@ -27,4 +27,36 @@ type Chef interface {
 
 	// Return deployments/updates hitory
 	History() error
+
+	// Adopts existing cloud resources into the stack, by ingredient name, and confirms that none would be replaced.
+	Import(mapping ImportMapping) error
+
+	// Returns the resource graph of the stack, highlighting the changes an update would make if preview is true.
+	Graph(preview bool) (Graph, error)
+
+	// Renders templates with the outputs of the stack and writes them.
+	Render(templates []TemplateConfig) error
+
+	// Deploys the deployment target currently deployed in another environment, whose stack is in the source state
+	// store, and records the promotion.
+	Promote(from ApplicationEnvironment, source StateStore) error
+
+	// Returns true if the stack and its state store must not be modified.
+	ReadOnly() bool
+}
+
+// ChefOption configures a chef.
+type ChefOption func(*chefOptions)
+
+type chefOptions struct {
+	programOptions []ProgramOption // The options of the Pulumi program deploying the recipes.
+	retry          RetryPolicy     // The policy retrying updates failing with transient errors.
+	target         string          // The deployment target, e.g. the Git hash of the working tree.
+}
+
+// Configures the Pulumi program deploying the recipes, e.g. with WithStandardTags.
+func WithProgramOptions(opts ...ProgramOption) ChefOption {
+	return func(co *chefOptions) {
+		co.programOptions = append(co.programOptions, opts...)
+	}
 }
@@ -0,0 +1,410 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/events"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optdestroy"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optpreview"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optrefresh"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optup"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"sourcesign.de/cloudprism/internal/apexwriter"
+)
+
+type defaultChef struct {
+	app     Application            // The application, used as the Pulumi project name.
+	env     ApplicationEnvironment // The application environment, used as the Pulumi stack name.
+	store   StateStore             // The state store keeping the stack.
+	recipes []Recipe               // The recipes making up the stack.
+	options chefOptions            // The options the chef was created with.
+
+	deployed   *deploymentRecord            // The deployment recorded by the last update, nil if unknown.
+	migrations map[string][]RecipeMigration // The migrations planned for the next update, by recipe name.
+	imports    ImportMapping                // The cloud resources to import in the next update.
+}
+
+// The URL schemes of the self-managed Pulumi backends.
+// nolint: gochecknoglobals
+var selfManagedBackendSchemes = []string{"file://", "s3://", "azblob://", "gs://"}
+
+// Returns a chef deploying the recipes of an application environment, as a stack kept in the state store.
+// The chef is read-only if the state store is.
+func GetDefaultChef(app Application, env ApplicationEnvironment, store StateStore, opts ...ChefOption) (Chef, error) {
+	if app.ID() == "" {
+		return nil, fmt.Errorf("application name is empty")
+	}
+
+	options := chefOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &defaultChef{
+		app:     app,
+		env:     env,
+		store:   store,
+		recipes: make([]Recipe, 0),
+		options: options,
+	}, nil
+}
+
+// Up implements Chef.
+func (dc *defaultChef) Up() error {
+	if err := checkReadOnly(dc.store, "Up of "+dc.stackName()); err != nil {
+		return err
+	}
+
+	return dc.up([]string{dc.env.ID()})
+}
+
+// Creates or updates the stack, and records the promotion chain of the deployment target.
+func (dc *defaultChef) up(chain []string) error {
+	if err := ValidateRecipes(dc.recipes); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	stack, err := dc.getStack(ctx, true)
+	if err != nil {
+		return err
+	}
+
+	if err := dc.planMigrations(ctx, stack); err != nil {
+		return err
+	}
+
+	err = runIngredientHooks(dc.recipes, "BeforeUpsert", func(hook BeforeUpsertIngredient) error {
+		return hook.BeforeUpsert(ctx)
+	})
+	if err != nil {
+		return err
+	}
+
+	record := dc.deploymentRecord(chain)
+
+	var result auto.UpResult
+
+	err = dc.retry(ctx, "up", func() error {
+		result, err = stack.Up(ctx, optup.ProgressStreams(dc.progressWriter("up")), optup.Message(record.message()))
+
+		return err
+	})
+	if err != nil {
+		log.WithFields(dc.fields()).WithError(err).Error("DefaultChef up failed")
+
+		return err
+	}
+
+	results := outputValues(result.Outputs)
+
+	err = runIngredientHooks(dc.recipes, "AfterUpsert", func(hook AfterUpsertIngredient) error {
+		return hook.AfterUpsert(ctx, results)
+	})
+
+	// The update succeeded, record it even if a hook failed.
+	dc.recordRecipeVersions(ctx, stack)
+	dc.recordDeployment(ctx, stack, chain)
+
+	return err
+}
+
+// Preview implements Chef.
+func (dc *defaultChef) Preview() error {
+	if err := ValidateRecipes(dc.recipes); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	stack, err := dc.getStack(ctx, !dc.ReadOnly())
+	if err != nil {
+		return err
+	}
+
+	if err := dc.planMigrations(ctx, stack); err != nil {
+		return err
+	}
+
+	_, err = stack.Preview(ctx, optpreview.ProgressStreams(dc.progressWriter("preview")))
+	if err != nil {
+		log.WithFields(dc.fields()).WithError(err).Error("DefaultChef preview failed")
+
+		return err
+	}
+
+	return nil
+}
+
+// Down implements Chef.
+func (dc *defaultChef) Down() error {
+	if err := checkReadOnly(dc.store, "Down of "+dc.stackName()); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	stack, err := dc.getStack(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	outputs, err := stack.Outputs(ctx)
+	if err != nil {
+		log.WithFields(dc.fields()).WithError(err).Error("DefaultChef reading outputs failed")
+
+		return err
+	}
+
+	results := outputValues(outputs)
+
+	err = runIngredientHooks(dc.recipes, "BeforeDelete", func(hook BeforeDeleteIngredient) error {
+		return hook.BeforeDelete(ctx, results)
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = stack.Destroy(ctx, optdestroy.ProgressStreams(dc.progressWriter("down")))
+	if err != nil {
+		log.WithFields(dc.fields()).WithError(err).Error("DefaultChef down failed")
+
+		return err
+	}
+
+	return nil
+}
+
+// Destroy implements Chef.
+func (dc *defaultChef) Destroy(force bool) error {
+	if err := checkReadOnly(dc.store, "Destroy of "+dc.stackName()); err != nil {
+		return err
+	}
+
+	if err := dc.Down(); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	stack, err := dc.getStack(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	if err := stack.Workspace().RemoveStack(ctx, stack.Name()); err != nil {
+		log.WithFields(dc.fields()).WithError(err).Error("DefaultChef remove stack failed")
+
+		return err
+	}
+
+	return dc.store.StoreDelete(force)
+}
+
+// ProjectName implements Chef.
+func (dc *defaultChef) ProjectName() string {
+	return dc.app.ID()
+}
+
+// Append implements Chef.
+func (dc *defaultChef) Append(recipes ...Recipe) {
+	dc.recipes = append(dc.recipes, recipes...)
+}
+
+// Refresh implements Chef.
+func (dc *defaultChef) Refresh() error {
+	if err := checkReadOnly(dc.store, "Refresh of "+dc.stackName()); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	stack, err := dc.getStack(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	_, err = stack.Refresh(ctx, optrefresh.ProgressStreams(dc.progressWriter("refresh")))
+	if err != nil {
+		log.WithFields(dc.fields()).WithError(err).Error("DefaultChef refresh failed")
+
+		return err
+	}
+
+	return nil
+}
+
+// Results implements Chef.
+func (dc *defaultChef) Results() (map[string]interface{}, error) {
+	ctx := context.Background()
+
+	stack, err := dc.getStack(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	outputs, err := stack.Outputs(ctx)
+	if err != nil {
+		log.WithFields(dc.fields()).WithError(err).Error("DefaultChef reading outputs failed")
+
+		return nil, err
+	}
+
+	return outputValues(outputs), nil
+}
+
+// History implements Chef.
+func (dc *defaultChef) History() error {
+	ctx := context.Background()
+
+	stack, err := dc.getStack(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	history, err := stack.History(ctx, 0, 0)
+	if err != nil {
+		log.WithFields(dc.fields()).WithError(err).Error("DefaultChef reading history failed")
+
+		return err
+	}
+
+	for _, update := range history {
+		log.WithFields(dc.fields()).WithFields(log.Fields{
+			"version": update.Version,
+			"kind":    update.Kind,
+			"start":   update.StartTime,
+			"result":  update.Result,
+		}).Info(update.Message)
+	}
+
+	return nil
+}
+
+// ReadOnly implements Chef.
+func (dc *defaultChef) ReadOnly() bool {
+	return dc.store.ReadOnly()
+}
+
+// The Pulumi program of the stack.
+func (dc *defaultChef) program(ctx *pulumi.Context) error {
+	opts := append([]ProgramOption{WithRecipeMigrations(dc.migrations), WithImports(dc.imports)}, dc.options.programOptions...)
+
+	return GetProgram(dc.recipes, opts...)(ctx)
+}
+
+// Opens the state store and selects the stack, creating it first if create is true.
+func (dc *defaultChef) getStack(ctx context.Context, create bool) (auto.Stack, error) {
+	stateURI, err := dc.store.StoreOpen()
+	if err != nil {
+		return auto.Stack{}, err
+	}
+
+	opts, err := GetWorkspaceOptions(dc.store, stateURI)
+	if err != nil {
+		return auto.Stack{}, err
+	}
+
+	ws, err := getProjectWorkspace(ctx, dc.ProjectName(), append(opts, auto.Program(dc.program))...)
+	if err != nil {
+		return auto.Stack{}, err
+	}
+
+	if create {
+		return auto.UpsertStack(ctx, dc.stackName(), ws)
+	}
+
+	stack, err := auto.SelectStack(ctx, dc.stackName(), ws)
+	if err != nil {
+		log.WithFields(dc.fields()).WithError(err).Error("DefaultChef select stack failed")
+
+		return auto.Stack{}, err
+	}
+
+	return stack, nil
+}
+
+// Returns true if the backend of the stack keeps stack tags.
+func stackTagsSupported(stack auto.Stack) bool {
+	return backendKeepsStackTags(stack.Workspace().GetEnvVars()["PULUMI_BACKEND_URL"])
+}
+
+// Returns true if a backend keeps stack tags. Only Pulumi Cloud does, the self-managed backends, e.g. file:// and
+// s3://, refuse to set them.
+func backendKeepsStackTags(backend string) bool {
+	for _, scheme := range selfManagedBackendSchemes {
+		if strings.HasPrefix(backend, scheme) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (dc *defaultChef) stackName() string {
+	return dc.env.ID()
+}
+
+func (dc *defaultChef) fields() log.Fields {
+	return log.Fields{
+		"project": dc.ProjectName(),
+		"stack":   dc.stackName(),
+	}
+}
+
+// Returns a writer logging the Pulumi progress output of an operation.
+func (dc *defaultChef) progressWriter(operation string) *apexwriter.Writer {
+	fields := dc.fields()
+	fields["operation"] = operation
+
+	return apexwriter.NewWriter(fields)
+}
+
+// Previews the stack, handing every engine event to handle, and returns once no more events are handled.
+func (dc *defaultChef) previewEvents(ctx context.Context, stack auto.Stack, handle func(events.EngineEvent),
+	opts ...optpreview.Option,
+) (auto.PreviewResult, error) {
+	stream := make(chan events.EngineEvent)
+	stop := make(chan struct{})
+	done := make(chan struct{}, 1)
+
+	go func() {
+		defer func() { done <- struct{}{} }()
+
+		for {
+			select {
+			case event, ok := <-stream:
+				if !ok {
+					return
+				}
+
+				handle(event)
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	result, err := stack.Preview(ctx, append(opts, optpreview.EventStreams(stream))...)
+
+	// All events are delivered once the preview returned, but the stream is not closed if it failed to start.
+	close(stop)
+	<-done
+
+	return result, err
+}
+
+// Returns the plain values of stack outputs.
+func outputValues(outputs auto.OutputMap) map[string]interface{} {
+	values := make(map[string]interface{}, len(outputs))
+	for k, v := range outputs {
+		values[k] = v.Value
+	}
+
+	return values
+}
@@ -0,0 +1,25 @@
+package common
+
+import "testing"
+
+func TestBackendKeepsStackTags(t *testing.T) {
+	tests := []struct {
+		backend string
+		want    bool
+	}{
+		{backend: "file:///home/me/.cloudprism/shop-dev", want: false},
+		{backend: "s3://shop-dev-state", want: false},
+		{backend: "azblob://state", want: false},
+		{backend: "gs://state", want: false},
+		{backend: "https://api.pulumi.com", want: true},
+		{backend: "", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.backend, func(t *testing.T) {
+			if got := backendKeepsStackTags(tt.backend); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigError is a problem with a value in rapid.yaml, pointing to its exact position where known.
+type ConfigError struct {
+	File   string // The config file, empty if unknown.
+	Line   int    // The line of the offending value, 0 if unknown.
+	Column int    // The column of the offending value, 0 if unknown.
+	Path   string // The path to the offending value, e.g. "recipes[0].ingredients[2].type".
+	Err    error  // The actual problem.
+}
+
+func (e *ConfigError) Error() string {
+	position := e.File
+
+	if e.Line > 0 {
+		position = fmt.Sprintf("%s:%d:%d", position, e.Line, e.Column)
+	}
+
+	if position != "" {
+		position += ": "
+	}
+
+	return position + e.Path + ": " + e.Err.Error()
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// ConfigErrors collects all problems found in rapid.yaml, so they can be reported at once.
+type ConfigErrors []*ConfigError
+
+func (e ConfigErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// Returns the errors as an error, or nil if there are none.
+func (e ConfigErrors) OrNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+
+	return e
+}
+
+// The position of a value in a config file, used to point errors to it.
+type configPosition struct {
+	file string     // The config file, empty if unknown.
+	node *yaml.Node // The YAML node of the value, nil if the config was not read from YAML.
+}
+
+// Returns an error for the value at path, positioned at the value of the given keys below this position.
+// Missing keys position the error at the deepest node found.
+func (p configPosition) errorf(path string, keys []string, format string, args ...interface{}) *ConfigError {
+	err := &ConfigError{
+		File: p.file,
+		Path: path,
+		Err:  fmt.Errorf(format, args...),
+	}
+
+	node := p.node
+	for _, key := range keys {
+		value := yamlMappingValue(node, key)
+		if value == nil {
+			break
+		}
+
+		node = value
+	}
+
+	if node != nil {
+		err.Line = node.Line
+		err.Column = node.Column
+	}
+
+	return err
+}
+
+// Returns the position of the value below the given key.
+func (p configPosition) child(key string) configPosition {
+	return configPosition{file: p.file, node: yamlMappingValue(p.node, key)}
+}
+
+// Returns the position of the i-th item of a sequence value.
+func (p configPosition) item(i int) configPosition {
+	if p.node == nil || p.node.Kind != yaml.SequenceNode || i >= len(p.node.Content) {
+		return configPosition{file: p.file}
+	}
+
+	return configPosition{file: p.file, node: p.node.Content[i]}
+}
+
+// Returns the position of the value a JSON pointer like "/rules/0/port" points to, relative to this position.
+// Values that cannot be found are positioned at the deepest value found.
+func (p configPosition) pointer(pointer string) configPosition {
+	pos := p
+
+	for _, token := range pointerTokens(pointer) {
+		next := pos.child(token)
+
+		if index, err := strconv.Atoi(token); err == nil && pos.node != nil && pos.node.Kind == yaml.SequenceNode {
+			next = pos.item(index)
+		}
+
+		if next.node == nil {
+			break
+		}
+
+		pos = next
+	}
+
+	return pos
+}
+
+// Returns an error for every key of this mapping position that is not one of the known keys.
+func (p configPosition) unknownKeys(path string, known ...string) ConfigErrors {
+	errs := ConfigErrors{}
+
+	if p.node == nil || p.node.Kind != yaml.MappingNode {
+		return errs
+	}
+
+	for i := 0; i+1 < len(p.node.Content); i += 2 {
+		key := p.node.Content[i]
+
+		isKnown := false
+
+		for _, k := range known {
+			if key.Value == k {
+				isKnown = true
+			}
+		}
+
+		if !isKnown {
+			errs = append(errs, &ConfigError{
+				File:   p.file,
+				Line:   key.Line,
+				Column: key.Column,
+				Path:   path,
+				Err:    fmt.Errorf("unknown field %q, expected one of %s", key.Value, strings.Join(known, ", ")),
+			})
+		}
+	}
+
+	return errs
+}
+
+// Returns the value node of a key in a YAML mapping node, or nil.
+func yamlMappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil {
+		return nil
+	}
+
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+// Reads the "recipes" section of a config file, keeping the position of every value for error messages.
+func LoadRecipeConfigs(file string) ([]RecipeConfig, error) {
+	content, err := os.ReadFile(file) // #nosec G304 -- the config file is chosen by the user
+	if err != nil {
+		return nil, err
+	}
+
+	doc := struct {
+		Recipes []RecipeConfig `yaml:"recipes"`
+	}{}
+
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	for i := range doc.Recipes {
+		doc.Recipes[i].position.file = file
+	}
+
+	return doc.Recipes, nil
+}
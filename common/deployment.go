@@ -0,0 +1,92 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+)
+
+// The prefix of the update messages recording a deployment.
+const deploymentMessagePrefix = "cloudprism: "
+
+// deploymentRecord describes what an update deployed. It is recorded in the message of the update, which every
+// backend keeps in the history of the stack, unlike stack tags, which the self-managed backends do not keep.
+type deploymentRecord struct {
+	// The deployment target, i.e. the Git hash, deployed by the update.
+	Target string `json:"target,omitempty"`
+	// The environments the deployment target was promoted through, ending with the updated one.
+	Promotion []string `json:"promotion,omitempty"`
+	// The versions of the versioned recipes, like "webservice@3", by recipe name.
+	Recipes map[string]string `json:"recipes,omitempty"`
+}
+
+// Returns the update message recording the deployment.
+func (dr *deploymentRecord) message() string {
+	// A struct of strings always marshals.
+	data, _ := json.Marshal(dr)
+
+	return deploymentMessagePrefix + string(data)
+}
+
+// Returns the deployment recorded in an update message, or nil if the message records none.
+func parseDeploymentMessage(message string) *deploymentRecord {
+	if !strings.HasPrefix(message, deploymentMessagePrefix) {
+		return nil
+	}
+
+	record := &deploymentRecord{}
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(message, deploymentMessagePrefix)), record); err != nil {
+		return nil
+	}
+
+	return record
+}
+
+// Returns the deployment of a stack according to its history, newest update first. The deployment is empty if the
+// stack was never updated or was destroyed since, and nil if the last successful update recorded none, e.g.
+// because it was run by the Pulumi CLI.
+func lastDeployment(history []auto.UpdateSummary) *deploymentRecord {
+	for _, update := range history {
+		if update.Result != string(apitype.SucceededResult) {
+			continue
+		}
+
+		switch update.Kind {
+		case string(apitype.DestroyUpdate):
+			return &deploymentRecord{}
+		case string(apitype.UpdateUpdate):
+			return parseDeploymentMessage(update.Message)
+		}
+	}
+
+	return &deploymentRecord{}
+}
+
+// Reads the deployment of the stack from its history.
+func (dc *defaultChef) readDeployment(ctx context.Context, stack auto.Stack) (*deploymentRecord, error) {
+	history, err := stack.History(ctx, 0, 0)
+	if err != nil {
+		log.WithFields(dc.fields()).WithError(err).Error("DefaultChef reading history failed")
+
+		return nil, err
+	}
+
+	return lastDeployment(history), nil
+}
+
+// Returns the record of the deployment of the recipes of the chef, promoted through the environments of the chain.
+func (dc *defaultChef) deploymentRecord(chain []string) *deploymentRecord {
+	record := &deploymentRecord{Target: dc.options.target, Promotion: chain, Recipes: map[string]string{}}
+
+	for _, recipe := range flattenRecipes(dc.recipes) {
+		if versioned, ok := recipe.(VersionedRecipe); ok {
+			record.Recipes[recipe.Name()] = recipeVersionTagValue(versioned)
+		}
+	}
+
+	return record
+}
@@ -0,0 +1,87 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+)
+
+func TestDeploymentMessage(t *testing.T) {
+	record := &deploymentRecord{Recipes: map[string]string{"web": "webservice@3"}}
+
+	message := record.message()
+	if message != `cloudprism: {"recipes":{"web":"webservice@3"}}` {
+		t.Errorf("unexpected message %q", message)
+	}
+
+	if got := parseDeploymentMessage(message); !reflect.DeepEqual(got, record) {
+		t.Errorf("expected %+v, got %+v", record, got)
+	}
+
+	for _, message := range []string{"", "fix the bucket", "cloudprism: {", `cloudprism {"recipes":{}}`} {
+		if got := parseDeploymentMessage(message); got != nil {
+			t.Errorf("expected %q to record no deployment, got %+v", message, got)
+		}
+	}
+}
+
+func TestLastDeployment(t *testing.T) {
+	recorded := `cloudprism: {"recipes":{"web":"webservice@2"}}`
+	older := `cloudprism: {"recipes":{"web":"webservice@1"}}`
+	web2 := &deploymentRecord{Recipes: map[string]string{"web": "webservice@2"}}
+
+	tests := []struct {
+		name    string
+		history []auto.UpdateSummary
+		want    *deploymentRecord
+	}{
+		{name: "never updated", want: &deploymentRecord{}},
+		{
+			name:    "updated",
+			history: []auto.UpdateSummary{{Kind: "update", Result: "succeeded", Message: recorded}},
+			want:    web2,
+		},
+		{
+			name: "latest successful update",
+			history: []auto.UpdateSummary{
+				{Kind: "refresh", Result: "succeeded"},
+				{Kind: "update", Result: "failed", Message: older},
+				{Kind: "update", Result: "succeeded", Message: recorded},
+				{Kind: "update", Result: "succeeded", Message: older},
+			},
+			want: web2,
+		},
+		{
+			name: "destroyed",
+			history: []auto.UpdateSummary{
+				{Kind: "destroy", Result: "succeeded"},
+				{Kind: "update", Result: "succeeded", Message: recorded},
+			},
+			want: &deploymentRecord{},
+		},
+		{
+			name: "destroy failed",
+			history: []auto.UpdateSummary{
+				{Kind: "destroy", Result: "failed"},
+				{Kind: "update", Result: "succeeded", Message: recorded},
+			},
+			want: web2,
+		},
+		{
+			name: "updated without record",
+			history: []auto.UpdateSummary{
+				{Kind: "update", Result: "succeeded", Message: "pulumi up by hand"},
+				{Kind: "update", Result: "succeeded", Message: recorded},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lastDeployment(tt.history); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
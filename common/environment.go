@@ -0,0 +1,164 @@
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// ProtectionLevel is how carefully the resources of an application environment are treated by default.
+type ProtectionLevel string
+
+const (
+	ProtectionNone    ProtectionLevel = "none"    // Resources may be deleted.
+	ProtectionRetain  ProtectionLevel = "retain"  // Cloud resources are kept when they are deleted from the stack.
+	ProtectionProtect ProtectionLevel = "protect" // Resources are protected and kept when deleted from the stack.
+)
+
+// EnvironmentConfig is an entry of the "environments" section of rapid.yaml. Entries with the ID of a built-in
+// environment, i.e. sbx, dev, int or prd, change it, the others add environments like qa, perf or dr.
+type EnvironmentConfig struct {
+	ID         string          `mapstructure:"id" yaml:"id"`                 // The short ID, e.g. "qa", used in stack names.
+	Name       string          `mapstructure:"name" yaml:"name"`             // The name, e.g. "Quality Assurance".
+	Order      *int            `mapstructure:"order" yaml:"order"`           // The position in the promotion order, e.g. 0 for sbx and 3 for prd.
+	Protection ProtectionLevel `mapstructure:"protection" yaml:"protection"` // Defaults to none.
+	Region     string          `mapstructure:"region" yaml:"region"`         // The default region, empty for the global one.
+}
+
+type environmentDefinition struct {
+	id         string
+	name       string
+	order      int
+	protection ProtectionLevel
+	region     string
+}
+
+// nolint: gochecknoglobals
+var (
+	builtinEnvironments = []environmentDefinition{
+		{id: "sbx", name: "Sandbox", order: 0, protection: ProtectionNone},
+		{id: "dev", name: "Development", order: 1, protection: ProtectionNone},
+		{id: "int", name: "Integration", order: 2, protection: ProtectionNone},
+		{id: "prd", name: "Production", order: 3, protection: ProtectionProtect},
+	}
+
+	environmentCatalogueMu sync.RWMutex
+	environmentCatalogue   = append([]environmentDefinition{}, builtinEnvironments...)
+
+	environmentIDPattern = regexp.MustCompile(`^[a-z][a-z0-9]{0,7}$`)
+)
+
+// Configures the environment catalogue: the built-in environments changed and extended by the configured ones.
+// The built-in environments keep their values, e.g. AppEnvProduction, added environments follow in the given order.
+func ConfigureEnvironments(cfgs []EnvironmentConfig) error {
+	catalogue := append([]environmentDefinition{}, builtinEnvironments...)
+	seen := map[string]bool{}
+
+	for i, cfg := range cfgs {
+		if !environmentIDPattern.MatchString(cfg.ID) {
+			return fmt.Errorf("environments[%d]: invalid ID %q, expected up to 8 lowercase letters and digits", i, cfg.ID)
+		}
+
+		if seen[cfg.ID] {
+			return fmt.Errorf("environments[%d]: environment %q is defined twice", i, cfg.ID)
+		}
+
+		seen[cfg.ID] = true
+
+		switch cfg.Protection {
+		case "", ProtectionNone, ProtectionRetain, ProtectionProtect:
+		default:
+			return fmt.Errorf("environments[%d]: invalid protection %q, expected none, retain or protect", i, cfg.Protection)
+		}
+
+		index := -1
+
+		for j, def := range catalogue {
+			if def.id == cfg.ID {
+				index = j
+			}
+		}
+
+		if index < 0 {
+			catalogue = append(catalogue, environmentDefinition{id: cfg.ID, name: cfg.ID, order: len(catalogue), protection: ProtectionNone})
+			index = len(catalogue) - 1
+		}
+
+		def := &catalogue[index]
+
+		if cfg.Name != "" {
+			def.name = cfg.Name
+		}
+
+		if cfg.Order != nil {
+			def.order = *cfg.Order
+		}
+
+		if cfg.Protection != "" {
+			def.protection = cfg.Protection
+		}
+
+		def.region = cfg.Region
+	}
+
+	environmentCatalogueMu.Lock()
+	defer environmentCatalogueMu.Unlock()
+
+	environmentCatalogue = catalogue
+
+	return nil
+}
+
+// Returns the environments of the catalogue, by order.
+func Environments() []ApplicationEnvironment {
+	environmentCatalogueMu.RLock()
+	defer environmentCatalogueMu.RUnlock()
+
+	envs := make([]ApplicationEnvironment, 0, len(environmentCatalogue))
+	for i := range environmentCatalogue {
+		envs = append(envs, ApplicationEnvironment(i))
+	}
+
+	sort.SliceStable(envs, func(i, j int) bool {
+		return environmentCatalogue[envs[i]].order < environmentCatalogue[envs[j]].order
+	})
+
+	return envs
+}
+
+// Returns the definition of an environment of the catalogue.
+func (s ApplicationEnvironment) definition() (environmentDefinition, bool) {
+	environmentCatalogueMu.RLock()
+	defer environmentCatalogueMu.RUnlock()
+
+	if int(s) >= len(environmentCatalogue) {
+		return environmentDefinition{}, false
+	}
+
+	return environmentCatalogue[s], true
+}
+
+// Returns the position of the environment in the promotion order.
+func (s ApplicationEnvironment) Order() int {
+	def, _ := s.definition()
+
+	return def.order
+}
+
+// Returns the protection level of the environment.
+func (s ApplicationEnvironment) Protection() ProtectionLevel {
+	def, ok := s.definition()
+	if !ok {
+		return ProtectionNone
+	}
+
+	return def.protection
+}
+
+// Returns the default region of the environment, empty if none is configured.
+func (s ApplicationEnvironment) Region() string {
+	def, _ := s.definition()
+
+	return def.region
+}
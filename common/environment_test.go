@@ -0,0 +1,124 @@
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConfigureEnvironments(t *testing.T) {
+	order := func(i int) *int { return &i }
+
+	tests := []struct {
+		name    string
+		cfgs    []EnvironmentConfig
+		want    []string
+		wantErr string
+	}{
+		{name: "built-in only", want: []string{"sbx", "dev", "int", "prd"}},
+		{
+			name: "added after the built-in ones",
+			cfgs: []EnvironmentConfig{{ID: "qa"}, {ID: "dr"}},
+			want: []string{"sbx", "dev", "int", "prd", "qa", "dr"},
+		},
+		{
+			name: "added with an order",
+			cfgs: []EnvironmentConfig{{ID: "qa", Order: order(2)}, {ID: "int", Order: order(3)}, {ID: "prd", Order: order(4)}},
+			want: []string{"sbx", "dev", "qa", "int", "prd"},
+		},
+		{name: "invalid ID", cfgs: []EnvironmentConfig{{ID: "Quality"}}, wantErr: "environments[0]: invalid ID"},
+		{name: "ID too long", cfgs: []EnvironmentConfig{{ID: "qualityas"}}, wantErr: "invalid ID"},
+		{name: "defined twice", cfgs: []EnvironmentConfig{{ID: "qa"}, {ID: "qa"}}, wantErr: "environments[1]: environment \"qa\" is defined twice"},
+		{name: "invalid protection", cfgs: []EnvironmentConfig{{ID: "qa", Protection: "keep"}}, wantErr: "invalid protection"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(resetEnvironments(t))
+
+			err := ConfigureEnvironments(tt.cfgs)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+
+				if got := environmentIDs(); len(got) != 4 {
+					t.Errorf("expected the catalogue to be kept, got %v", got)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := environmentIDs(); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConfigureEnvironmentsChangesBuiltins(t *testing.T) {
+	t.Cleanup(resetEnvironments(t))
+
+	err := ConfigureEnvironments([]EnvironmentConfig{
+		{ID: "int", Name: "Staging", Protection: ProtectionRetain, Region: "eu-west-1"},
+		{ID: "qa", Name: "Quality Assurance", Protection: ProtectionProtect},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qa, ok := environmentByID("qa")
+	if !ok {
+		t.Fatal("qa is not in the catalogue")
+	}
+
+	tests := []struct {
+		env        ApplicationEnvironment
+		name       string
+		protection ProtectionLevel
+		region     string
+	}{
+		{env: AppEnvIntegration, name: "Staging", protection: ProtectionRetain, region: "eu-west-1"},
+		{env: AppEnvProduction, name: "Production", protection: ProtectionProtect},
+		{env: qa, name: "Quality Assurance", protection: ProtectionProtect},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.env.ID(), func(t *testing.T) {
+			if tt.env.Name() != tt.name || tt.env.Protection() != tt.protection || tt.env.Region() != tt.region {
+				t.Errorf("expected %s, %s, %q, got %s, %s, %q", tt.name, tt.protection, tt.region,
+					tt.env.Name(), tt.env.Protection(), tt.env.Region())
+			}
+		})
+	}
+
+	if err := ConfigureEnvironments(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if AppEnvIntegration.Name() != "Integration" || qa.ID() != "etc" {
+		t.Errorf("expected the built-in catalogue back, got %s and %s", AppEnvIntegration.Name(), qa.ID())
+	}
+}
+
+// Returns a function restoring the built-in environment catalogue.
+func resetEnvironments(t *testing.T) func() {
+	return func() {
+		if err := ConfigureEnvironments(nil); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func environmentIDs() []string {
+	ids := []string{}
+	for _, env := range Environments() {
+		ids = append(ids, env.ID())
+	}
+
+	return ids
+}
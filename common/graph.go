@@ -0,0 +1,350 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/events"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+)
+
+// Graph is a directed graph of ingredients or resources, rendered as Graphviz DOT or Mermaid.
+type Graph struct {
+	Nodes []GraphNode
+	Edges []GraphEdge
+}
+
+// GraphNode is an ingredient or a resource.
+type GraphNode struct {
+	ID     string         // The unique ID, e.g. the URN of a resource.
+	Label  string         // The text shown.
+	Group  string         // The group the node is drawn in, e.g. its recipe, empty for none.
+	Change apitype.OpType // The change a preview found, empty if the node is unchanged or no preview was run.
+}
+
+// GraphEdge points from a node to a node it depends on.
+type GraphEdge struct {
+	From   string // The ID of the dependent node.
+	To     string // The ID of the node depended on.
+	Parent bool   // True if To is the parent of From rather than a dependency.
+}
+
+// The colors of the changes found by a preview.
+// nolint: gochecknoglobals
+var graphChangeColors = map[apitype.OpType]string{
+	apitype.OpCreate:            "#2e7d32",
+	apitype.OpImport:            "#2e7d32",
+	apitype.OpUpdate:            "#ef6c00",
+	apitype.OpDelete:            "#c62828",
+	apitype.OpReplace:           "#c62828",
+	apitype.OpCreateReplacement: "#c62828",
+	apitype.OpDeleteReplaced:    "#c62828",
+}
+
+// Returns the graph of the ingredients of the recipes, grouped by recipe, with edges for their dependencies.
+func GetRecipeGraph(recipes []Recipe) Graph {
+	graph := Graph{}
+	ids := map[string]string{}
+
+	for _, recipe := range flattenRecipes(recipes) {
+		for i, ingredient := range recipe.Ingredients() {
+			id := ingredientPath(recipe, i, ingredient)
+
+			if name := IngredientName(ingredient); name != "" {
+				ids[name] = id
+			}
+
+			graph.Nodes = append(graph.Nodes, GraphNode{
+				ID:    id,
+				Label: strings.TrimPrefix(id, recipe.Name()+"/"),
+				Group: recipe.Name(),
+			})
+		}
+	}
+
+	for _, recipe := range flattenRecipes(recipes) {
+		for i, ingredient := range recipe.Ingredients() {
+			dependent, ok := ingredientAs[DependentIngredient](ingredient)
+			if !ok {
+				continue
+			}
+
+			for _, dep := range dependent.DependsOn() {
+				if to, ok := ids[dep]; ok {
+					graph.Edges = append(graph.Edges, GraphEdge{From: ingredientPath(recipe, i, ingredient), To: to})
+				}
+			}
+		}
+	}
+
+	return graph
+}
+
+// Returns the graph of the resources of a deployment, with edges for their dependencies and parents.
+// The changes map URNs to the changes a preview found, resources only found by the preview are added.
+func getStateGraph(deployment apitype.DeploymentV3, changes map[string]apitype.OpType) Graph {
+	graph := Graph{}
+	known := map[string]bool{}
+
+	for _, res := range deployment.Resources {
+		urn := string(res.URN)
+		known[urn] = true
+
+		graph.Nodes = append(graph.Nodes, GraphNode{
+			ID:     urn,
+			Label:  res.URN.Name() + "\n" + string(res.Type),
+			Change: changes[urn],
+		})
+
+		if res.Parent != "" {
+			graph.Edges = append(graph.Edges, GraphEdge{From: urn, To: string(res.Parent), Parent: true})
+		}
+
+		for _, dep := range res.Dependencies {
+			graph.Edges = append(graph.Edges, GraphEdge{From: urn, To: string(dep)})
+		}
+	}
+
+	added := []string{}
+
+	for urn := range changes {
+		if !known[urn] {
+			added = append(added, urn)
+		}
+	}
+
+	sort.Strings(added)
+
+	for _, urn := range added {
+		graph.Nodes = append(graph.Nodes, GraphNode{
+			ID:     urn,
+			Label:  urnLabel(urn),
+			Change: changes[urn],
+		})
+	}
+
+	return graph
+}
+
+// Returns a label like "name\ntype" for a URN like "urn:pulumi:stack::project::type::name".
+func urnLabel(urn string) string {
+	parts := strings.Split(urn, "::")
+	if len(parts) < 4 {
+		return urn
+	}
+
+	types := strings.Split(parts[2], "$")
+
+	return parts[3] + "\n" + types[len(types)-1]
+}
+
+// Graph implements Chef. If preview is true, the changes an update would make are highlighted.
+func (dc *defaultChef) Graph(preview bool) (Graph, error) {
+	ctx := context.Background()
+
+	stack, err := dc.getStack(ctx, false)
+	if err != nil {
+		return Graph{}, err
+	}
+
+	exported, err := stack.Export(ctx)
+	if err != nil {
+		log.WithFields(dc.fields()).WithError(err).Error("DefaultChef exporting stack failed")
+
+		return Graph{}, err
+	}
+
+	deployment := apitype.DeploymentV3{}
+	if len(exported.Deployment) > 0 {
+		if err := json.Unmarshal(exported.Deployment, &deployment); err != nil {
+			return Graph{}, err
+		}
+	}
+
+	changes := map[string]apitype.OpType{}
+
+	if preview {
+		if err := dc.planMigrations(ctx, stack); err != nil {
+			return Graph{}, err
+		}
+
+		_, err := dc.previewEvents(ctx, stack, func(event events.EngineEvent) {
+			recordPreviewChange(changes, event)
+		})
+		if err != nil {
+			log.WithFields(dc.fields()).WithError(err).Error("DefaultChef preview failed")
+
+			return Graph{}, err
+		}
+	}
+
+	return getStateGraph(deployment, changes), nil
+}
+
+// Records the change of a resource found by a preview, unchanged resources are left out.
+func recordPreviewChange(changes map[string]apitype.OpType, event events.EngineEvent) {
+	if event.ResourcePreEvent == nil {
+		return
+	}
+
+	metadata := event.ResourcePreEvent.Metadata
+	if metadata.Op != apitype.OpSame && metadata.Op != apitype.OpRead {
+		changes[metadata.URN] = metadata.Op
+	}
+}
+
+// Returns the graph in the Graphviz DOT language.
+func (g Graph) DOT() string {
+	dot := &strings.Builder{}
+
+	fmt.Fprintf(dot, "digraph cloudprism {\n")
+	fmt.Fprintf(dot, "  rankdir=LR;\n")
+	fmt.Fprintf(dot, "  node [shape=box, style=rounded, fontname=\"Helvetica\"];\n")
+
+	for i, group := range g.groups() {
+		fmt.Fprintf(dot, "  subgraph cluster_%d {\n", i)
+		fmt.Fprintf(dot, "    label=%s;\n", strconv.Quote(group))
+
+		for _, node := range g.Nodes {
+			if node.Group == group {
+				fmt.Fprintf(dot, "    %s;\n", node.dot())
+			}
+		}
+
+		fmt.Fprintf(dot, "  }\n")
+	}
+
+	for _, node := range g.Nodes {
+		if node.Group == "" {
+			fmt.Fprintf(dot, "  %s;\n", node.dot())
+		}
+	}
+
+	for _, edge := range g.Edges {
+		style := ""
+		if edge.Parent {
+			style = " [style=dashed, arrowhead=none]"
+		}
+
+		fmt.Fprintf(dot, "  %s -> %s%s;\n", strconv.Quote(edge.From), strconv.Quote(edge.To), style)
+	}
+
+	fmt.Fprintf(dot, "}\n")
+
+	return dot.String()
+}
+
+func (n GraphNode) dot() string {
+	attributes := []string{"label=" + strconv.Quote(n.label())}
+
+	if color, ok := graphChangeColors[n.Change]; ok {
+		attributes = append(attributes, "color="+strconv.Quote(color), "fontcolor="+strconv.Quote(color), "penwidth=2")
+	}
+
+	return strconv.Quote(n.ID) + " [" + strings.Join(attributes, ", ") + "]"
+}
+
+// Returns the graph as a Mermaid flowchart.
+func (g Graph) Mermaid() string {
+	mermaid := &strings.Builder{}
+	ids := make(map[string]string, len(g.Nodes))
+
+	for i, node := range g.Nodes {
+		ids[node.ID] = "n" + strconv.Itoa(i)
+	}
+
+	fmt.Fprintf(mermaid, "flowchart LR\n")
+
+	for i, group := range g.groups() {
+		fmt.Fprintf(mermaid, "  subgraph g%d [%s]\n", i, mermaidText(group))
+
+		for _, node := range g.Nodes {
+			if node.Group == group {
+				fmt.Fprintf(mermaid, "    %s[%s]\n", ids[node.ID], mermaidText(node.label()))
+			}
+		}
+
+		fmt.Fprintf(mermaid, "  end\n")
+	}
+
+	for _, node := range g.Nodes {
+		if node.Group == "" {
+			fmt.Fprintf(mermaid, "  %s[%s]\n", ids[node.ID], mermaidText(node.label()))
+		}
+	}
+
+	for _, edge := range g.Edges {
+		from, fromOK := ids[edge.From]
+		to, toOK := ids[edge.To]
+
+		if !fromOK || !toOK {
+			continue
+		}
+
+		arrow := "-->"
+		if edge.Parent {
+			arrow = "-.-"
+		}
+
+		fmt.Fprintf(mermaid, "  %s %s %s\n", from, arrow, to)
+	}
+
+	changes := map[apitype.OpType][]string{}
+
+	for _, node := range g.Nodes {
+		if _, ok := graphChangeColors[node.Change]; ok {
+			changes[node.Change] = append(changes[node.Change], ids[node.ID])
+		}
+	}
+
+	ops := make([]string, 0, len(changes))
+	for op := range changes {
+		ops = append(ops, string(op))
+	}
+
+	sort.Strings(ops)
+
+	for _, op := range ops {
+		class := strings.ReplaceAll(op, "-", "_")
+		color := graphChangeColors[apitype.OpType(op)]
+
+		fmt.Fprintf(mermaid, "  classDef %s stroke:%s,stroke-width:3px,color:%s\n", class, color, color)
+		fmt.Fprintf(mermaid, "  class %s %s\n", strings.Join(changes[apitype.OpType(op)], ","), class)
+	}
+
+	return mermaid.String()
+}
+
+// Returns the label of a node, with the change found by a preview.
+func (n GraphNode) label() string {
+	if n.Change == "" {
+		return n.Label
+	}
+
+	return n.Label + "\n(" + string(n.Change) + ")"
+}
+
+// Returns the groups of the nodes, in order of appearance.
+func (g Graph) groups() []string {
+	groups := []string{}
+	seen := map[string]bool{}
+
+	for _, node := range g.Nodes {
+		if node.Group != "" && !seen[node.Group] {
+			seen[node.Group] = true
+			groups = append(groups, node.Group)
+		}
+	}
+
+	return groups
+}
+
+// Quotes a text for Mermaid, with line breaks.
+func mermaidText(text string) string {
+	return "\"" + strings.ReplaceAll(strings.ReplaceAll(text, "\"", "#quot;"), "\n", "<br>") + "\""
+}
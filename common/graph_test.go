@@ -0,0 +1,156 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/events"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+)
+
+const (
+	stackURN  = "urn:pulumi:dev::shop::pulumi:pulumi:Stack::shop-dev"
+	bucketURN = "urn:pulumi:dev::shop::aws:s3/bucket:Bucket::assets"
+	policyURN = "urn:pulumi:dev::shop::aws:s3/bucketPolicy:BucketPolicy::assets"
+	queueURN  = "urn:pulumi:dev::shop::cloudprism:Queue$aws:sqs/queue:Queue::orders"
+)
+
+// The deployment of a stack with a bucket and its policy.
+func testDeployment() apitype.DeploymentV3 {
+	return apitype.DeploymentV3{Resources: []apitype.ResourceV3{
+		{URN: stackURN, Type: "pulumi:pulumi:Stack"},
+		{URN: bucketURN, Type: "aws:s3/bucket:Bucket", Parent: stackURN},
+		{URN: policyURN, Type: "aws:s3/bucketPolicy:BucketPolicy", Parent: stackURN, Dependencies: []resource.URN{bucketURN}},
+	}}
+}
+
+// Returns the changes recorded from the resource events of a preview.
+func previewChanges(ops map[string]apitype.OpType) map[string]apitype.OpType {
+	changes := map[string]apitype.OpType{}
+
+	recordPreviewChange(changes, events.EngineEvent{})
+
+	for urn, op := range ops {
+		recordPreviewChange(changes, events.EngineEvent{EngineEvent: apitype.EngineEvent{
+			ResourcePreEvent: &apitype.ResourcePreEvent{Metadata: apitype.StepEventMetadata{URN: urn, Op: op}},
+		}})
+	}
+
+	return changes
+}
+
+func TestGetStateGraph(t *testing.T) {
+	graph := getStateGraph(testDeployment(), map[string]apitype.OpType{})
+
+	want := Graph{
+		Nodes: []GraphNode{
+			{ID: stackURN, Label: "shop-dev\npulumi:pulumi:Stack"},
+			{ID: bucketURN, Label: "assets\naws:s3/bucket:Bucket"},
+			{ID: policyURN, Label: "assets\naws:s3/bucketPolicy:BucketPolicy"},
+		},
+		Edges: []GraphEdge{
+			{From: bucketURN, To: stackURN, Parent: true},
+			{From: policyURN, To: stackURN, Parent: true},
+			{From: policyURN, To: bucketURN},
+		},
+	}
+
+	if !reflect.DeepEqual(graph, want) {
+		t.Errorf("expected %+v, got %+v", want, graph)
+	}
+}
+
+func TestGetStateGraphWithPreview(t *testing.T) {
+	changes := previewChanges(map[string]apitype.OpType{
+		stackURN:  apitype.OpSame,
+		bucketURN: apitype.OpUpdate,
+		policyURN: apitype.OpRead,
+		queueURN:  apitype.OpCreate,
+	})
+
+	graph := getStateGraph(testDeployment(), changes)
+
+	got := map[string]apitype.OpType{}
+	for _, node := range graph.Nodes {
+		got[node.Label] = node.Change
+	}
+
+	want := map[string]apitype.OpType{
+		"shop-dev\npulumi:pulumi:Stack":            "",
+		"assets\naws:s3/bucket:Bucket":             apitype.OpUpdate,
+		"assets\naws:s3/bucketPolicy:BucketPolicy": "",
+		"orders\naws:sqs/queue:Queue":              apitype.OpCreate,
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the changes %v, got %v", want, got)
+	}
+}
+
+func TestGraphDOT(t *testing.T) {
+	graph := Graph{
+		Nodes: []GraphNode{
+			{ID: "web/bucket", Label: "bucket", Group: "web"},
+			{ID: "web/cdn", Label: "cdn", Group: "web", Change: apitype.OpCreate},
+			{ID: "dns", Label: "dns \"zone\""},
+		},
+		Edges: []GraphEdge{
+			{From: "web/cdn", To: "web/bucket"},
+			{From: "web/bucket", To: "dns", Parent: true},
+		},
+	}
+
+	want := `digraph cloudprism {
+  rankdir=LR;
+  node [shape=box, style=rounded, fontname="Helvetica"];
+  subgraph cluster_0 {
+    label="web";
+    "web/bucket" [label="bucket"];
+    "web/cdn" [label="cdn\n(create)", color="#2e7d32", fontcolor="#2e7d32", penwidth=2];
+  }
+  "dns" [label="dns \"zone\""];
+  "web/cdn" -> "web/bucket";
+  "web/bucket" -> "dns" [style=dashed, arrowhead=none];
+}
+`
+
+	if got := graph.DOT(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestGraphMermaid(t *testing.T) {
+	graph := Graph{
+		Nodes: []GraphNode{
+			{ID: "web/bucket", Label: "bucket", Group: "web", Change: apitype.OpDelete},
+			{ID: "web/cdn", Label: "cdn", Group: "web", Change: apitype.OpCreate},
+			{ID: "web/certificate", Label: "certificate", Group: "web", Change: apitype.OpCreate},
+			{ID: "dns", Label: "dns \"zone\"\nroute53"},
+		},
+		Edges: []GraphEdge{
+			{From: "web/cdn", To: "web/bucket"},
+			{From: "web/bucket", To: "dns", Parent: true},
+			{From: "web/cdn", To: "elsewhere"},
+		},
+	}
+
+	want := `flowchart LR
+  subgraph g0 ["web"]
+    n0["bucket<br>(delete)"]
+    n1["cdn<br>(create)"]
+    n2["certificate<br>(create)"]
+  end
+  n3["dns #quot;zone#quot;<br>route53"]
+  n1 --> n0
+  n0 -.- n3
+  classDef create stroke:#2e7d32,stroke-width:3px,color:#2e7d32
+  class n1,n2 create
+  classDef delete stroke:#c62828,stroke-width:3px,color:#c62828
+  class n0 delete
+`
+
+	if got := graph.Mermaid(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}
@@ -0,0 +1,296 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/events"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optpreview"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optup"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"gopkg.in/yaml.v3"
+)
+
+// ImportMapping maps ingredient names to the IDs of the cloud resources to import into them, by logical resource
+// name. The empty resource name stands for the only resource of an ingredient. In a mapping file it reads like:
+//
+//	bucket: my-hand-made-bucket
+//	network:
+//	  vpc: vpc-0123456789abcdef0
+//	  subnet-a: subnet-0123456789abcdef0
+type ImportMapping map[string]map[string]string
+
+// Reads an import mapping file. All problems are returned at once as ConfigErrors.
+func LoadImportMapping(file string) (ImportMapping, error) {
+	content, err := os.ReadFile(file) // #nosec G304 -- the mapping file is chosen by the user
+	if err != nil {
+		return nil, err
+	}
+
+	doc := yaml.Node{}
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	mapping := ImportMapping{}
+	errs := ConfigErrors{}
+
+	root := &doc
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+
+	if root.Kind != yaml.MappingNode {
+		return nil, &ConfigError{File: file, Line: root.Line, Column: root.Column, Err: fmt.Errorf("expected ingredient names mapped to resource IDs")}
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		ingredient, value := root.Content[i].Value, root.Content[i+1]
+
+		switch value.Kind {
+		case yaml.ScalarNode:
+			mapping[ingredient] = map[string]string{"": value.Value}
+		case yaml.MappingNode:
+			mapping[ingredient] = map[string]string{}
+
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				id := value.Content[j+1]
+				if id.Kind != yaml.ScalarNode {
+					errs = append(errs, &ConfigError{File: file, Line: id.Line, Column: id.Column, Path: ingredient + "." + value.Content[j].Value, Err: fmt.Errorf("expected a resource ID")})
+
+					continue
+				}
+
+				mapping[ingredient][value.Content[j].Value] = id.Value
+			}
+		default:
+			errs = append(errs, &ConfigError{File: file, Line: value.Line, Column: value.Column, Path: ingredient, Err: fmt.Errorf("expected a resource ID or resource names mapped to resource IDs")})
+		}
+	}
+
+	if err := errs.OrNil(); err != nil {
+		return nil, err
+	}
+
+	return mapping, nil
+}
+
+// Imports the cloud resources of the mapping into the resources the ingredients create, instead of creating them.
+func WithImports(mapping ImportMapping) ProgramOption {
+	return func(opts *programOptions) {
+		opts.imports = mapping
+	}
+}
+
+// A stack transformation adding the import option to the resources listed in the import mapping.
+func (ps *programState) importResource(args *pulumi.ResourceTransformationArgs) *pulumi.ResourceTransformationResult {
+	if ps.ingredient == nil {
+		return nil
+	}
+
+	if _, custom := args.Resource.(pulumi.CustomResource); !custom {
+		return nil
+	}
+
+	name := IngredientName(ps.ingredient)
+
+	ids, ok := ps.options.imports[name]
+	if !ok {
+		return nil
+	}
+
+	key := args.Name
+	if _, ok := ids[key]; !ok {
+		key = ""
+	}
+
+	id, ok := ids[key]
+	if !ok {
+		return nil
+	}
+
+	if ps.imported[name] == nil {
+		ps.imported[name] = map[string]string{}
+	}
+
+	if other, used := ps.imported[name][key]; used {
+		ps.errs = append(ps.errs, fmt.Errorf("ingredient %s creates the resources %s and %s, map their IDs by resource name", name, other, args.Name))
+
+		return nil
+	}
+
+	ps.imported[name][key] = args.Name
+
+	log.WithFields(log.Fields{
+		"ingredient": name,
+		"resource":   args.Name,
+		"type":       args.Type,
+		"id":         id,
+	}).Info("Importing resource")
+
+	return &pulumi.ResourceTransformationResult{
+		Props: args.Props,
+		Opts:  append(args.Opts, pulumi.Import(pulumi.ID(id))),
+	}
+}
+
+// Returns true if a resource is imported, by the import mapping or by its ingredient.
+func importedResource(args *pulumi.ResourceTransformationArgs) bool {
+	opts, err := pulumi.NewResourceOptions(args.Opts...)
+
+	return err == nil && opts.Import != nil
+}
+
+// Returns an error for the problems found importing resources, and for entries of the mapping no resource matched.
+func (ps *programState) importErrors() error {
+	problems := []string{}
+
+	for _, err := range ps.errs {
+		problems = append(problems, err.Error())
+	}
+
+	for name, ids := range ps.options.imports {
+		for key := range ids {
+			if _, used := ps.imported[name][key]; used {
+				continue
+			}
+
+			if key == "" {
+				problems = append(problems, fmt.Sprintf("ingredient %s created no resource to import", name))
+			} else {
+				problems = append(problems, fmt.Sprintf("ingredient %s created no resource %s to import", name, key))
+			}
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	sort.Strings(problems)
+
+	return fmt.Errorf("importing resources failed:\n%s", strings.Join(problems, "\n"))
+}
+
+// Import implements Chef. The resources to import are adopted by an update limited to them, then a preview of the
+// whole stack confirms that no resource would be replaced.
+func (dc *defaultChef) Import(mapping ImportMapping) error {
+	if err := checkReadOnly(dc.store, "Import into "+dc.stackName()); err != nil {
+		return err
+	}
+
+	if err := ValidateRecipes(dc.recipes); err != nil {
+		return err
+	}
+
+	if err := dc.checkImportMapping(mapping); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	stack, err := dc.getStack(ctx, true)
+	if err != nil {
+		return err
+	}
+
+	if err := dc.planMigrations(ctx, stack); err != nil {
+		return err
+	}
+
+	dc.imports = mapping
+	defer func() { dc.imports = nil }()
+
+	urns, err := dc.previewImports(ctx, stack)
+	if err != nil {
+		return err
+	}
+
+	if len(urns) == 0 {
+		return fmt.Errorf("no resource to import, they may be in the stack already")
+	}
+
+	opts := []optup.Option{optup.Target(urns), optup.ProgressStreams(dc.progressWriter("import"))}
+
+	// Only the imported resources are updated, so the deployment stays the recorded one.
+	if dc.deployed != nil {
+		opts = append(opts, optup.Message(dc.deployed.message()))
+	}
+
+	_, err = stack.Up(ctx, opts...)
+	if err != nil {
+		log.WithFields(dc.fields()).WithError(err).Error("DefaultChef import failed")
+
+		return err
+	}
+
+	dc.imports = nil
+
+	preview, err := stack.Preview(ctx, optpreview.ProgressStreams(dc.progressWriter("preview")))
+	if err != nil {
+		log.WithFields(dc.fields()).WithError(err).Error("DefaultChef preview after import failed")
+
+		return err
+	}
+
+	replaced := preview.ChangeSummary[apitype.OpReplace] + preview.ChangeSummary[apitype.OpCreateReplacement] +
+		preview.ChangeSummary[apitype.OpDeleteReplaced]
+	if replaced > 0 {
+		return fmt.Errorf("imported %d resource(s), but the next update would replace %d resource(s), align the ingredients with the imported resources", len(urns), replaced)
+	}
+
+	log.WithFields(dc.fields()).WithField("resources", len(urns)).Info("Imported resources, no resource would be replaced")
+
+	return nil
+}
+
+// Checks that all ingredients of the mapping exist.
+func (dc *defaultChef) checkImportMapping(mapping ImportMapping) error {
+	names := map[string]bool{}
+
+	for _, recipe := range flattenRecipes(dc.recipes) {
+		for _, ingredient := range recipe.Ingredients() {
+			names[IngredientName(ingredient)] = true
+		}
+	}
+
+	unknown := []string{}
+
+	for name := range mapping {
+		if name == "" || !names[name] {
+			unknown = append(unknown, fmt.Sprintf("%q", name))
+		}
+	}
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+
+		return fmt.Errorf("unknown ingredients in import mapping: %s", strings.Join(unknown, ", "))
+	}
+
+	return nil
+}
+
+// Previews the import and returns the URNs of the resources that would be imported.
+func (dc *defaultChef) previewImports(ctx context.Context, stack auto.Stack) ([]string, error) {
+	urns := []string{}
+
+	_, err := dc.previewEvents(ctx, stack, func(event events.EngineEvent) {
+		if event.ResourcePreEvent != nil && event.ResourcePreEvent.Metadata.Op == apitype.OpImport {
+			urns = append(urns, event.ResourcePreEvent.Metadata.URN)
+		}
+	}, optpreview.ProgressStreams(dc.progressWriter("preview-import")))
+	if err != nil {
+		log.WithFields(dc.fields()).WithError(err).Error("DefaultChef preview of import failed")
+
+		return nil, err
+	}
+
+	return urns, nil
+}
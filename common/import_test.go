@@ -0,0 +1,76 @@
+package common_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"sourcesign.de/cloudprism/common"
+	"sourcesign.de/cloudprism/common/recipetest"
+)
+
+const bucketType = "test:index:Bucket"
+
+type bucket struct {
+	Tags map[string]string `pulumi:"tags"`
+}
+
+type bucketArgs struct {
+	Tags pulumi.StringMapInput
+}
+
+func (bucketArgs) ElementType() reflect.Type {
+	return reflect.TypeOf((*bucket)(nil)).Elem()
+}
+
+// bucketsIngredient registers a taggable bucket per name.
+type bucketsIngredient struct {
+	names []string
+}
+
+func (bi *bucketsIngredient) Name() string {
+	return "buckets"
+}
+
+func (bi *bucketsIngredient) Upsert(ctx *pulumi.Context) error {
+	for _, name := range bi.names {
+		args := &bucketArgs{Tags: pulumi.StringMap{"Name": pulumi.String(name)}}
+		if err := ctx.RegisterResource(bucketType, name, args, &pulumi.CustomResourceState{}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (bi *bucketsIngredient) Result() interface{} {
+	return nil
+}
+
+func TestImportedResourcesKeepTheirInputs(t *testing.T) {
+	recipe := common.GetDefaultRecipe("storage")
+	recipe.Append(&bucketsIngredient{names: []string{"hand-made", "new"}})
+
+	result, err := recipetest.Run([]common.Recipe{recipe}, recipetest.WithProgramOptions(
+		common.WithStandardTags(map[string]string{"Environment": "dev"}),
+		common.WithResourcePolicy(common.ResourcePolicy{Protect: true}),
+		common.WithImports(common.ImportMapping{"buckets": {"hand-made": "hand-made-bucket"}}),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imported := result.AssertNamedResource(t, bucketType, "hand-made")
+	if imported.ID != "hand-made-bucket" {
+		t.Errorf("expected the imported ID, got %q", imported.ID)
+	}
+
+	if tags, _ := imported.Input("tags"); !reflect.DeepEqual(tags, map[string]interface{}{"Name": "hand-made"}) {
+		t.Errorf("expected the imported bucket to keep its own tags only, got %v", tags)
+	}
+
+	created := result.AssertNamedResource(t, bucketType, "new")
+	if tags, _ := created.Input("tags"); !reflect.DeepEqual(tags, map[string]interface{}{"Name": "new", "Environment": "dev"}) {
+		t.Errorf("expected the created bucket to get the standard tags, got %v", tags)
+	}
+}
@@ -10,3 +10,49 @@ type Ingredient interface {
 	Upsert(ctx *pulumi.Context) error
 	Result() interface{}
 }
+
+// NamedIngredient is implemented by ingredients with a name, unique within their recipe.
+type NamedIngredient interface {
+	Name() string
+}
+
+// DependentIngredient is implemented by ingredients depending on other, named ingredients of their recipe.
+type DependentIngredient interface {
+	DependsOn() []string
+}
+
+// Returns the name of an ingredient, or an empty string for anonymous ingredients.
+func IngredientName(ingredient Ingredient) string {
+	if named, ok := ingredientAs[NamedIngredient](ingredient); ok {
+		return named.Name()
+	}
+
+	return ""
+}
+
+// Returns the ingredient as T, looking through ingredients wrapping others with an Unwrap() Ingredient method.
+func ingredientAs[T any](ingredient Ingredient) (T, bool) {
+	for ingredient != nil {
+		if t, ok := ingredient.(T); ok {
+			return t, true
+		}
+
+		wrapper, ok := ingredient.(interface{ Unwrap() Ingredient })
+		if !ok {
+			break
+		}
+
+		ingredient = wrapper.Unwrap()
+	}
+
+	var zero T
+
+	return zero, false
+}
+
+// OutputIngredient is implemented by ingredients exporting stack outputs. The chef exports them namespaced by
+// recipe, i.e. as entries of a map output named like the top-level recipe, with a nested map per included recipe.
+type OutputIngredient interface {
+	// Returns the outputs, called after Upsert.
+	Outputs() pulumi.Map
+}
@@ -0,0 +1,407 @@
+package common
+
+import (
+	"fmt"
+)
+
+// IngredientConfig is a declarative ingredient in the "ingredients" list of a recipe in rapid.yaml.
+type IngredientConfig struct {
+	// The type name of a registered ingredient.
+	Type string `mapstructure:"type" yaml:"type"`
+	// The name of the ingredient, unique within its recipe.
+	Name string `mapstructure:"name" yaml:"name"`
+	// The properties passed to the ingredient factory.
+	Properties map[string]interface{} `mapstructure:"properties" yaml:"properties,omitempty"`
+	// The names of other ingredients of the same recipe that must be upserted first.
+	DependsOn []string `mapstructure:"dependsOn" yaml:"dependsOn,omitempty"`
+	// Tags overriding the standard tags of the resources of the ingredient, an empty value removes a tag.
+	Tags map[string]string `mapstructure:"tags" yaml:"tags,omitempty"`
+	// The parts of the resource policy of the environment the resources of the ingredient opt out of.
+	PolicyOptOut *ResourcePolicyOptOut `mapstructure:"policyOptOut" yaml:"policyOptOut,omitempty"`
+	// The IDs or names of the environments the ingredient exists in, e.g. [prd], all environments if empty.
+	Environments []string `mapstructure:"environments" yaml:"environments,omitempty"`
+	// Properties by environment ID or name, merged recursively over the properties above.
+	Overrides map[string]map[string]interface{} `mapstructure:"overrides" yaml:"overrides,omitempty"`
+}
+
+// Returns true if the ingredient exists in an application environment.
+func (cfg IngredientConfig) EnabledIn(env ApplicationEnvironment) bool {
+	return environmentsContain(cfg.Environments, env)
+}
+
+// Returns the ingredient as configured for an application environment, with the overrides of the environment
+// merged into the properties, and without any environment specific settings.
+func (cfg IngredientConfig) ForEnvironment(env ApplicationEnvironment) IngredientConfig {
+	if key, ok := overridesKey(cfg.Overrides, env); ok {
+		cfg.Properties = mergeProperties(cfg.Properties, cfg.Overrides[key])
+	}
+
+	cfg.Environments = nil
+	cfg.Overrides = nil
+
+	return cfg
+}
+
+// Returns true if a list of environment IDs or names contains an application environment. An empty list contains
+// all environments.
+func environmentsContain(environments []string, env ApplicationEnvironment) bool {
+	if len(environments) == 0 {
+		return true
+	}
+
+	for _, text := range environments {
+		if isEnvironment(text, env) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the key of the overrides of an application environment, and false if the environment has none.
+func overridesKey(overrides map[string]map[string]interface{}, env ApplicationEnvironment) (string, bool) {
+	for _, key := range sortedKeys(overrides) {
+		if isEnvironment(key, env) {
+			return key, true
+		}
+	}
+
+	return "", false
+}
+
+// Returns true if a text is the ID or name of an application environment, see ParseApplicationEnvironment.
+func isEnvironment(text string, env ApplicationEnvironment) bool {
+	parsed, err := ParseApplicationEnvironment(text)
+
+	return err == nil && parsed == env
+}
+
+// Returns the problems of the environments and overrides of a recipe or ingredient: unknown environments, and
+// environments overridden twice, e.g. by ID and by name.
+func environmentProblems(environments []string, overrides map[string]map[string]interface{}, pos configPosition, path string) ConfigErrors {
+	errs := ConfigErrors{}
+
+	for i, text := range environments {
+		if _, err := ParseApplicationEnvironment(text); err != nil {
+			errs = append(errs, pos.child("environments").item(i).errorf(fmt.Sprintf("%s.environments[%d]", path, i), nil, "%w", err))
+		}
+	}
+
+	overridden := map[ApplicationEnvironment]string{}
+
+	for _, key := range sortedKeys(overrides) {
+		env, err := ParseApplicationEnvironment(key)
+		if err != nil {
+			errs = append(errs, pos.errorf(path+".overrides."+key, []string{"overrides", key}, "%w", err))
+		} else if other, exists := overridden[env]; exists {
+			errs = append(errs, pos.errorf(path+".overrides."+key, []string{"overrides", key}, "environment %s is overridden by %q already", env.ID(), other))
+		} else {
+			overridden[env] = key
+		}
+	}
+
+	return errs
+}
+
+// Returns the properties with the overrides merged in recursively: maps are merged, other values replaced.
+func mergeProperties(properties, overrides map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(properties)+len(overrides))
+
+	for key, value := range properties {
+		merged[key] = value
+	}
+
+	for key, value := range overrides {
+		base, baseIsMap := merged[key].(map[string]interface{})
+		override, overrideIsMap := value.(map[string]interface{})
+
+		if baseIsMap && overrideIsMap {
+			merged[key] = mergeProperties(base, override)
+		} else {
+			merged[key] = value
+		}
+	}
+
+	return merged
+}
+
+// An ingredient created from its declarative definition, wrapping the ingredient returned by the factory.
+type declaredIngredient struct {
+	Ingredient
+	name       string                 // The name of the ingredient.
+	registered RegisteredIngredient   // The registered ingredient the factory was taken from.
+	properties map[string]interface{} // The declared properties.
+	dependsOn  []string               // The names of the ingredients this one depends on.
+	tags       map[string]string      // The declared tag overrides.
+	optOut     *ResourcePolicyOptOut  // The declared resource policy opt-out, nil if none.
+}
+
+// Name implements NamedIngredient.
+func (di *declaredIngredient) Name() string {
+	return di.name
+}
+
+// DependsOn implements DependentIngredient.
+func (di *declaredIngredient) DependsOn() []string {
+	return di.dependsOn
+}
+
+// Tags implements TaggingIngredient, merging the declared tags over the ones of the created ingredient.
+func (di *declaredIngredient) Tags() map[string]string {
+	tags := map[string]string{}
+
+	if inner, ok := ingredientAs[TaggingIngredient](di.Ingredient); ok {
+		for key, value := range inner.Tags() {
+			tags[key] = value
+		}
+	}
+
+	for key, value := range di.tags {
+		tags[key] = value
+	}
+
+	return tags
+}
+
+// ResourcePolicyOptOut implements PolicyOptOutIngredient, with the declared opt-out if there is one.
+func (di *declaredIngredient) ResourcePolicyOptOut() ResourcePolicyOptOut {
+	if di.optOut != nil {
+		return *di.optOut
+	}
+
+	if inner, ok := ingredientAs[PolicyOptOutIngredient](di.Ingredient); ok {
+		return inner.ResourcePolicyOptOut()
+	}
+
+	return ResourcePolicyOptOut{}
+}
+
+// Returns the ingredient created by the factory.
+func (di *declaredIngredient) Unwrap() Ingredient {
+	return di.Ingredient
+}
+
+// InputSchema implements SchemaIngredient, with the schema of the registered ingredient if it has one.
+func (di *declaredIngredient) InputSchema() string {
+	if di.registered.Schema != "" {
+		return di.registered.Schema
+	}
+
+	if inner, ok := ingredientAs[SchemaIngredient](di.Ingredient); ok {
+		return inner.InputSchema()
+	}
+
+	return ""
+}
+
+// Inputs implements SchemaIngredient, returning the declared properties if the registered ingredient has a schema.
+func (di *declaredIngredient) Inputs() interface{} {
+	if di.registered.Schema != "" {
+		return di.properties
+	}
+
+	if inner, ok := ingredientAs[SchemaIngredient](di.Ingredient); ok {
+		return inner.Inputs()
+	}
+
+	return nil
+}
+
+// Creates the declarative ingredients of a recipe, ordered so that every ingredient follows its dependencies.
+// The prefix is prepended to the names of the ingredients of included recipes. Ingredients not enabled in the
+// application environment are skipped, the others are created with the overrides of the environment.
+func getDeclaredIngredients(cfgs []IngredientConfig, pos configPosition, path, prefix string, env ApplicationEnvironment) ([]Ingredient, ConfigErrors) {
+	errs := ConfigErrors{}
+	byName := map[string]int{}
+
+	for i, cfg := range cfgs {
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+		itemPos := pos.item(i)
+
+		errs = append(errs, itemPos.unknownKeys(itemPath, "type", "name", "properties", "dependsOn", "tags", "policyOptOut",
+			"environments", "overrides")...)
+
+		errs = append(errs, environmentProblems(cfg.Environments, cfg.Overrides, itemPos, itemPath)...)
+
+		if cfg.PolicyOptOut != nil && cfg.PolicyOptOut.Reason == "" {
+			errs = append(errs, itemPos.errorf(itemPath+".policyOptOut", []string{"policyOptOut"}, "a reason must be given to opt out of the resource policy"))
+		}
+
+		if cfg.Type == "" {
+			errs = append(errs, itemPos.errorf(itemPath+".type", []string{"type"}, "type must be set"))
+		} else if _, ok := GetRegisteredIngredient(cfg.Type); !ok {
+			errs = append(errs, itemPos.errorf(itemPath+".type", []string{"type"}, "ingredient type %q is not registered", cfg.Type))
+		}
+
+		if cfg.Name == "" {
+			errs = append(errs, itemPos.errorf(itemPath+".name", []string{"name"}, "name must be set"))
+
+			continue
+		}
+
+		if _, exists := byName[cfg.Name]; exists {
+			errs = append(errs, itemPos.errorf(itemPath+".name", []string{"name"}, "ingredient name %q is used twice", cfg.Name))
+
+			continue
+		}
+
+		byName[cfg.Name] = i
+	}
+
+	for i, cfg := range cfgs {
+		for j, dep := range cfg.DependsOn {
+			depPath := fmt.Sprintf("%s[%d].dependsOn[%d]", path, i, j)
+			depPos := pos.item(i).child("dependsOn").item(j)
+
+			if dep == cfg.Name {
+				errs = append(errs, depPos.errorf(depPath, nil, "ingredient %q depends on itself", dep))
+			} else if j, ok := byName[dep]; !ok {
+				errs = append(errs, depPos.errorf(depPath, nil, "unknown ingredient %q", dep))
+			} else if cfg.EnabledIn(env) && !cfgs[j].EnabledIn(env) {
+				errs = append(errs, depPos.errorf(depPath, nil, "ingredient %q is not enabled in environment %s", dep, env.ID()))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	order, cycle := declaredIngredientOrder(cfgs, byName)
+	for _, i := range cycle {
+		errs = append(errs, pos.item(i).errorf(fmt.Sprintf("%s[%d].dependsOn", path, i), []string{"dependsOn"},
+			"ingredient %q is part of, or depends on, a dependency cycle", cfgs[i].Name))
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	created := make(map[string]Ingredient, len(cfgs))
+	ingredients := make([]Ingredient, 0, len(cfgs))
+
+	for _, i := range order {
+		if !cfgs[i].EnabledIn(env) {
+			continue
+		}
+
+		cfg := cfgs[i].ForEnvironment(env)
+		registered, _ := GetRegisteredIngredient(cfg.Type)
+
+		dependencies := make(map[string]IngredientDependency, len(cfg.DependsOn))
+		dependsOn := make([]string, 0, len(cfg.DependsOn))
+
+		for _, dep := range cfg.DependsOn {
+			dependencies[dep] = created[dep].Result
+			dependsOn = append(dependsOn, prefix+dep)
+		}
+
+		properties := cfg.Properties
+		if properties == nil {
+			properties = map[string]interface{}{}
+		}
+
+		if registered.Schema != "" {
+			propertiesPath := fmt.Sprintf("%s[%d].properties", path, i)
+
+			problems, err := validateInputs(cfg.Type, registered.Schema, properties)
+			if err != nil {
+				errs = append(errs, pos.item(i).errorf(propertiesPath, []string{"properties"}, "%w", err))
+
+				continue
+			}
+
+			for _, problem := range problems {
+				problemPos := pos.item(i).child("properties").pointer(problem.pointer)
+				if tokens := pointerTokens(problem.pointer); len(tokens) > 0 {
+					if key, ok := overridesKey(cfgs[i].Overrides, env); ok {
+						if _, overridden := cfgs[i].Overrides[key][tokens[0]]; overridden {
+							problemPos = pos.item(i).child("overrides").child(key).pointer(problem.pointer)
+						}
+					}
+				}
+
+				if problemPos.node == nil {
+					problemPos = pos.item(i)
+				}
+
+				errs = append(errs, problemPos.errorf(propertiesPath+pointerToPath(problem.pointer), nil, "%s", problem.message))
+			}
+
+			if len(problems) > 0 {
+				continue
+			}
+		}
+
+		ingredient, err := registered.Factory(prefix+cfg.Name, properties, dependencies)
+		if err != nil {
+			errs = append(errs, pos.item(i).errorf(fmt.Sprintf("%s[%d].properties", path, i), []string{"properties"}, "%w", err))
+
+			continue
+		}
+
+		wrapped := &declaredIngredient{
+			Ingredient: ingredient,
+			name:       prefix + cfg.Name,
+			registered: registered,
+			properties: properties,
+			dependsOn:  dependsOn,
+			tags:       cfg.Tags,
+			optOut:     cfg.PolicyOptOut,
+		}
+
+		created[cfg.Name] = wrapped
+		ingredients = append(ingredients, wrapped)
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	return ingredients, nil
+}
+
+// Orders the ingredients so that every ingredient follows its dependencies, keeping the declared order otherwise.
+// Returns the indexes of the ingredients that are part of a dependency cycle, if any.
+func declaredIngredientOrder(cfgs []IngredientConfig, byName map[string]int) ([]int, []int) {
+	done := make([]bool, len(cfgs))
+	order := make([]int, 0, len(cfgs))
+
+	for len(order) < len(cfgs) {
+		progress := false
+
+		for i, cfg := range cfgs {
+			if done[i] {
+				continue
+			}
+
+			ready := true
+
+			for _, dep := range cfg.DependsOn {
+				if !done[byName[dep]] {
+					ready = false
+				}
+			}
+
+			if ready {
+				done[i] = true
+				order = append(order, i)
+				progress = true
+			}
+		}
+
+		if !progress {
+			cycle := []int{}
+
+			for i := range cfgs {
+				if !done[i] {
+					cycle = append(cycle, i)
+				}
+			}
+
+			return order, cycle
+		}
+	}
+
+	return order, nil
+}
@@ -0,0 +1,314 @@
+package common
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+func TestMergeProperties(t *testing.T) {
+	tests := []struct {
+		name       string
+		properties map[string]interface{}
+		overrides  map[string]interface{}
+		want       map[string]interface{}
+	}{
+		{
+			name:       "no overrides",
+			properties: map[string]interface{}{"size": 1},
+			want:       map[string]interface{}{"size": 1},
+		},
+		{
+			name:      "no properties",
+			overrides: map[string]interface{}{"size": 2},
+			want:      map[string]interface{}{"size": 2},
+		},
+		{
+			name:       "value replaced and added",
+			properties: map[string]interface{}{"size": 1, "name": "db"},
+			overrides:  map[string]interface{}{"size": 2, "multiAz": true},
+			want:       map[string]interface{}{"size": 2, "name": "db", "multiAz": true},
+		},
+		{
+			name:       "maps merged recursively",
+			properties: map[string]interface{}{"backup": map[string]interface{}{"enabled": false, "window": "03:00"}},
+			overrides:  map[string]interface{}{"backup": map[string]interface{}{"enabled": true, "retention": 7}},
+			want:       map[string]interface{}{"backup": map[string]interface{}{"enabled": true, "window": "03:00", "retention": 7}},
+		},
+		{
+			name:       "lists replaced",
+			properties: map[string]interface{}{"zones": []interface{}{"a", "b"}},
+			overrides:  map[string]interface{}{"zones": []interface{}{"c"}},
+			want:       map[string]interface{}{"zones": []interface{}{"c"}},
+		},
+		{
+			name:       "map replaced by a value",
+			properties: map[string]interface{}{"backup": map[string]interface{}{"enabled": true}},
+			overrides:  map[string]interface{}{"backup": nil},
+			want:       map[string]interface{}{"backup": nil},
+		},
+		{
+			name:       "value replaced by a map",
+			properties: map[string]interface{}{"backup": false},
+			overrides:  map[string]interface{}{"backup": map[string]interface{}{"enabled": true}},
+			want:       map[string]interface{}{"backup": map[string]interface{}{"enabled": true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			properties := deepCopyProperties(tt.properties)
+
+			got := mergeProperties(tt.properties, tt.overrides)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+
+			if !reflect.DeepEqual(tt.properties, properties) {
+				t.Errorf("the properties were changed to %v", tt.properties)
+			}
+		})
+	}
+}
+
+func deepCopyProperties(properties map[string]interface{}) map[string]interface{} {
+	if properties == nil {
+		return nil
+	}
+
+	copied := make(map[string]interface{}, len(properties))
+
+	for key, value := range properties {
+		if nested, ok := value.(map[string]interface{}); ok {
+			value = deepCopyProperties(nested)
+		}
+
+		copied[key] = value
+	}
+
+	return copied
+}
+
+func TestIngredientConfigForEnvironment(t *testing.T) {
+	cfg := IngredientConfig{
+		Type:         "database",
+		Name:         "db",
+		Properties:   map[string]interface{}{"size": 1},
+		Environments: []string{"Production", "INT"},
+		Overrides: map[string]map[string]interface{}{
+			"production": {"size": 3},
+			"int":        {"size": 2},
+		},
+	}
+
+	tests := []struct {
+		env     ApplicationEnvironment
+		enabled bool
+		size    int
+	}{
+		{env: AppEnvProduction, enabled: true, size: 3},
+		{env: AppEnvIntegration, enabled: true, size: 2},
+		{env: AppEnvDevelopment, size: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.env.ID(), func(t *testing.T) {
+			if enabled := cfg.EnabledIn(tt.env); enabled != tt.enabled {
+				t.Errorf("expected enabled %v, got %v", tt.enabled, enabled)
+			}
+
+			resolved := cfg.ForEnvironment(tt.env)
+			if size := resolved.Properties["size"]; size != tt.size {
+				t.Errorf("expected size %d, got %v", tt.size, size)
+			}
+
+			if resolved.Environments != nil || resolved.Overrides != nil {
+				t.Errorf("expected no environment specific settings, got %+v", resolved)
+			}
+		})
+	}
+}
+
+func TestIngredientConfigEnvironmentProblems(t *testing.T) {
+	cfgs := []RecipeConfig{{Name: "web", Ingredients: []IngredientConfig{{
+		Type:         "cloudprism:stack-reference",
+		Name:         "network",
+		Properties:   map[string]interface{}{"application": "net", "environment": "prd"},
+		Environments: []string{"Production", "staging"},
+		Overrides: map[string]map[string]interface{}{
+			"prd":        {},
+			"Production": {},
+			"qa":         {},
+		},
+	}}}}
+
+	err := ValidateRecipeConfigs(cfgs, AppEnvProduction)
+
+	problems := ConfigErrors{}
+	if !errors.As(err, &problems) {
+		t.Fatalf("expected ConfigErrors, got %v", err)
+	}
+
+	want := []string{
+		`recipes[0].ingredients[0].environments[1]: unknown environment "staging"`,
+		`recipes[0].ingredients[0].overrides.prd: environment prd is overridden by "Production" already`,
+		`recipes[0].ingredients[0].overrides.qa: unknown environment "qa"`,
+	}
+
+	if len(problems) != len(want) {
+		t.Fatalf("expected %d problems, got %d:\n%v", len(want), len(problems), err)
+	}
+
+	for i, problem := range problems {
+		if !strings.Contains(problem.Error(), want[i]) {
+			t.Errorf("problem %d: %q does not contain %q", i, problem.Error(), want[i])
+		}
+	}
+}
+
+// sizedIngredient records the size parameter of the "test-sized" recipe.
+type sizedIngredient struct {
+	size interface{}
+}
+
+func (sizedIngredient) Upsert(*pulumi.Context) error { return nil }
+func (sizedIngredient) Result() interface{}          { return nil }
+
+// nolint: gochecknoinits
+func init() {
+	RegisterRecipe("test-sized", "A recipe recording its size parameter", func(name string, params map[string]interface{}) (Recipe, error) {
+		recipe := GetDefaultRecipe(name)
+		recipe.Append(sizedIngredient{size: params["size"]})
+
+		return recipe, nil
+	})
+}
+
+func TestRecipeConfigForEnvironment(t *testing.T) {
+	cfgs := []RecipeConfig{
+		{
+			Name:         "db",
+			Recipe:       "test-sized",
+			Params:       map[string]interface{}{"size": 1},
+			Environments: []string{"Production", "int"},
+			Overrides:    map[string]map[string]interface{}{"prd": {"size": 3}},
+			Ingredients:  []IngredientConfig{{Type: "database", Name: "replica", Environments: []string{"prd"}}},
+		},
+		{Name: "cache", Recipe: "test-sized"},
+	}
+
+	tests := []struct {
+		env     ApplicationEnvironment
+		recipes []string
+		size    interface{}
+	}{
+		{env: AppEnvProduction, recipes: []string{"db", "cache"}, size: 3},
+		{env: AppEnvIntegration, recipes: []string{"db", "cache"}, size: 1},
+		{env: AppEnvDevelopment, recipes: []string{"cache"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.env.ID(), func(t *testing.T) {
+			resolved := ResolveRecipeConfigs(cfgs, tt.env)
+
+			names := []string{}
+			for _, cfg := range resolved {
+				names = append(names, cfg.Name)
+			}
+
+			if !reflect.DeepEqual(names, tt.recipes) {
+				t.Fatalf("expected recipes %v, got %v", tt.recipes, names)
+			}
+
+			if resolved[0].Environments != nil || resolved[0].Overrides != nil {
+				t.Errorf("expected no environment specific settings, got %+v", resolved[0])
+			}
+
+			if tt.size == nil {
+				return
+			}
+
+			if size := resolved[0].Params["size"]; size != tt.size {
+				t.Errorf("expected the resolved size %v, got %v", tt.size, size)
+			}
+
+			if cfgs[0].Params["size"] != 1 {
+				t.Errorf("the parameters were changed to %v", cfgs[0].Params)
+			}
+		})
+	}
+}
+
+func TestGetRecipesForEnvironment(t *testing.T) {
+	cfgs := []RecipeConfig{
+		{
+			Name:         "db",
+			Recipe:       "test-sized",
+			Params:       map[string]interface{}{"size": 1},
+			Environments: []string{"prd", "INT"},
+			Overrides:    map[string]map[string]interface{}{"Production": {"size": 3}},
+		},
+		{Name: "cache", Recipe: "test-sized", Environments: []string{"dev"}},
+	}
+
+	tests := []struct {
+		env  ApplicationEnvironment
+		want map[string]interface{}
+	}{
+		{env: AppEnvProduction, want: map[string]interface{}{"db": 3}},
+		{env: AppEnvIntegration, want: map[string]interface{}{"db": 1}},
+		{env: AppEnvDevelopment, want: map[string]interface{}{"cache": nil}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.env.ID(), func(t *testing.T) {
+			recipes, err := GetRecipes(cfgs, tt.env)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sizes := map[string]interface{}{}
+			for _, recipe := range recipes {
+				sizes[recipe.Name()] = recipe.Ingredients()[0].(sizedIngredient).size
+			}
+
+			if !reflect.DeepEqual(sizes, tt.want) {
+				t.Errorf("expected sizes %v, got %v", tt.want, sizes)
+			}
+		})
+	}
+}
+
+func TestRecipeConfigEnvironmentProblems(t *testing.T) {
+	cfgs := []RecipeConfig{{
+		Name:         "db",
+		Recipe:       "test-sized",
+		Environments: []string{"staging"},
+		Overrides:    map[string]map[string]interface{}{"prd": {}, "Production": {}},
+	}}
+
+	err := ValidateRecipeConfigs(cfgs, AppEnvProduction)
+
+	problems := ConfigErrors{}
+	if !errors.As(err, &problems) {
+		t.Fatalf("expected ConfigErrors, got %v", err)
+	}
+
+	want := []string{
+		`recipes[0].environments[0]: unknown environment "staging"`,
+		`recipes[0].overrides.prd: environment prd is overridden by "Production" already`,
+	}
+
+	if len(problems) != len(want) {
+		t.Fatalf("expected %d problems, got %d:\n%v", len(want), len(problems), err)
+	}
+
+	for i, problem := range problems {
+		if !strings.Contains(problem.Error(), want[i]) {
+			t.Errorf("problem %d: %q does not contain %q", i, problem.Error(), want[i])
+		}
+	}
+}
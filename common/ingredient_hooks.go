@@ -0,0 +1,75 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/apex/log"
+)
+
+// ValidatingIngredient is implemented by ingredients checking themselves offline, before any state store is opened.
+type ValidatingIngredient interface {
+	Validate() error
+}
+
+// BeforeUpsertIngredient is implemented by ingredients that need to act before the stack is updated.
+type BeforeUpsertIngredient interface {
+	BeforeUpsert(ctx context.Context) error
+}
+
+// AfterUpsertIngredient is implemented by ingredients that need to act once the stack was updated successfully,
+// e.g. to seed a database. The results are the outputs of the stack.
+type AfterUpsertIngredient interface {
+	AfterUpsert(ctx context.Context, results map[string]interface{}) error
+}
+
+// BeforeDeleteIngredient is implemented by ingredients that need to act before the resources of the stack are
+// deleted, e.g. to snapshot a database. The results are the outputs of the stack.
+// The hook only runs when the whole stack is taken down. An ingredient removed from a recipe is no longer known to
+// the chef, so the update deleting its resources cannot call it: snapshot such resources before removing it, or
+// register them with the RetainOnDelete resource option.
+type BeforeDeleteIngredient interface {
+	BeforeDelete(ctx context.Context, results map[string]interface{}) error
+}
+
+// Calls a hook on every ingredient of the recipes implementing T, in order, stopping at the first error.
+// Ingredients of included recipes are called before the ones of their parents.
+func runIngredientHooks[T any](recipes []Recipe, hook string, call func(T) error) error {
+	for _, recipe := range flattenRecipes(recipes) {
+		for i, ingredient := range recipe.Ingredients() {
+			hooked, ok := ingredientAs[T](ingredient)
+			if !ok {
+				continue
+			}
+
+			path := ingredientPath(recipe, i, ingredient)
+
+			log.WithFields(log.Fields{
+				"ingredient": path,
+				"hook":       hook,
+			}).Debug("Running ingredient hook")
+
+			if err := call(hooked); err != nil {
+				log.WithFields(log.Fields{
+					"ingredient": path,
+					"hook":       hook,
+				}).WithError(err).Error("Ingredient hook failed")
+
+				return fmt.Errorf("%s %s: %w", path, hook, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Returns the path of an ingredient like "recipe/ingredient", using its index for anonymous ingredients.
+func ingredientPath(recipe Recipe, index int, ingredient Ingredient) string {
+	name := IngredientName(ingredient)
+	if name == "" {
+		name = "#" + strconv.Itoa(index)
+	}
+
+	return recipe.Name() + "/" + name
+}
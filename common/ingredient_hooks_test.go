@@ -0,0 +1,186 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// hookIngredient records the hooks called on it.
+type hookIngredient struct {
+	name  string
+	calls *[]string
+	err   error // Returned by every hook.
+}
+
+func (hi *hookIngredient) Upsert(*pulumi.Context) error { return nil }
+func (hi *hookIngredient) Result() interface{}          { return nil }
+func (hi *hookIngredient) Name() string                 { return hi.name }
+
+func (hi *hookIngredient) BeforeUpsert(context.Context) error {
+	*hi.calls = append(*hi.calls, "BeforeUpsert "+hi.name)
+
+	return hi.err
+}
+
+func (hi *hookIngredient) BeforeDelete(context.Context, map[string]interface{}) error {
+	*hi.calls = append(*hi.calls, "BeforeDelete "+hi.name)
+
+	return hi.err
+}
+
+// Returns the recipe "web" including the recipe "web-db", and the recipe "cache", with hook ingredients, an
+// ingredient without hooks, and a hook ingredient wrapped like a declared one. The hook of the ingredient named
+// failing fails.
+func hookRecipes(calls *[]string, failing string) []Recipe {
+	hook := func(name string) *hookIngredient {
+		hi := &hookIngredient{name: name, calls: calls}
+		if name == failing {
+			hi.err = errors.New("snapshot failed")
+		}
+
+		return hi
+	}
+
+	db := GetDefaultRecipe("web-db")
+	db.Append(hook("db"))
+
+	web := nestingRecipe("web", db)
+	web.Append(hook("frontend"), resultIngredient{}, &declaredIngredient{Ingredient: hook("worker"), name: "worker"})
+
+	cache := GetDefaultRecipe("cache")
+	cache.Append(hook("cache"))
+
+	return []Recipe{web, cache}
+}
+
+func TestRunIngredientHooks(t *testing.T) {
+	tests := []struct {
+		name    string
+		failing string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "in recipe and ingredient order",
+			want: []string{"BeforeDelete db", "BeforeDelete frontend", "BeforeDelete worker", "BeforeDelete cache"},
+		},
+		{
+			name:    "stops at the first failure",
+			failing: "worker",
+			want:    []string{"BeforeDelete db", "BeforeDelete frontend", "BeforeDelete worker"},
+			wantErr: "web/worker BeforeDelete: snapshot failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := []string{}
+
+			err := runIngredientHooks(hookRecipes(&calls, tt.failing), "BeforeDelete", func(hook BeforeDeleteIngredient) error {
+				return hook.BeforeDelete(context.Background(), nil)
+			})
+			if tt.wantErr == "" && err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Fatalf("expected the error %q, got %v", tt.wantErr, err)
+			}
+
+			if !reflect.DeepEqual(calls, tt.want) {
+				t.Errorf("expected the calls %v, got %v", tt.want, calls)
+			}
+		})
+	}
+}
+
+// A Pulumi CLI recording its commands in the file named by FAKE_PULUMI_LOG, with a stack without outputs or
+// history.
+const fakePulumi = `#!/bin/sh
+echo "$*" >> "$FAKE_PULUMI_LOG"
+case "$*" in
+version) echo "v3.147.0" ;;
+"stack output"*) echo "{}" ;;
+"stack history"*) echo "[]" ;;
+esac
+`
+
+// Puts a fake Pulumi CLI first in the path, and returns a function returning the commands it ran.
+func installFakePulumi(t *testing.T) func() string {
+	t.Helper()
+
+	dir := t.TempDir()
+	log := filepath.Join(dir, "commands.log")
+
+	if err := os.WriteFile(filepath.Join(dir, "pulumi"), []byte(fakePulumi), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("FAKE_PULUMI_LOG", log)
+	t.Setenv("PULUMI_CONFIG_PASSPHRASE", "test")
+
+	return func() string {
+		data, err := os.ReadFile(log)
+		if err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+
+		return string(data)
+	}
+}
+
+func TestFailingHookStopsTheUpdate(t *testing.T) {
+	commands := installFakePulumi(t)
+
+	store, err := GetDefaultStateStore(t.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chef, err := GetDefaultChef("shop", AppEnvDevelopment, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := []string{}
+	chef.Append(hookRecipes(&calls, "frontend")...)
+
+	tests := []struct {
+		name    string
+		run     func() error
+		command string
+		want    []string
+	}{
+		{name: "up", run: chef.Up, command: "up", want: []string{"BeforeUpsert db", "BeforeUpsert frontend"}},
+		{name: "down", run: chef.Down, command: "destroy", want: []string{"BeforeDelete db", "BeforeDelete frontend"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = calls[:0]
+
+			err := tt.run()
+			if err == nil || !strings.Contains(err.Error(), "web/frontend") {
+				t.Fatalf("expected the hook of web/frontend to fail, got %v", err)
+			}
+
+			if !reflect.DeepEqual(calls, tt.want) {
+				t.Errorf("expected the calls %v, got %v", tt.want, calls)
+			}
+
+			for _, command := range strings.Split(commands(), "\n") {
+				if strings.HasPrefix(command, tt.command+" ") {
+					t.Errorf("expected pulumi %s not to run, got %q", tt.command, command)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package common
+
+import (
+	"sort"
+	"sync"
+)
+
+// IngredientFactory creates a named ingredient from the properties given in rapid.yaml.
+// The dependencies hold the results of the ingredients listed in dependsOn, which are only
+// available once those were upserted, i.e. from within Upsert of the created ingredient.
+type IngredientFactory func(name string, properties map[string]interface{}, dependencies map[string]IngredientDependency) (Ingredient, error)
+
+type RegisteredIngredient struct {
+	Type        string            // The type name the ingredient is registered under, e.g. "aws:s3-bucket".
+	Description string            // A one-line description.
+	Schema      string            // The JSON Schema of the properties, empty if they are not validated.
+	Factory     IngredientFactory // Creates instances of the ingredient.
+}
+
+// nolint: gochecknoglobals
+var (
+	ingredientRegistryMu sync.RWMutex
+	ingredientRegistry   = map[string]RegisteredIngredient{}
+)
+
+// Registers an ingredient factory under a type name, usually from the init function of the package providing it.
+// Registering the same type name twice panics.
+func RegisterIngredient(typeName, description string, factory IngredientFactory) {
+	RegisterIngredientWithSchema(typeName, description, "", factory)
+}
+
+// Registers an ingredient factory like RegisterIngredient, with a JSON Schema the properties are validated against
+// before the factory is called. An invalid schema panics.
+func RegisterIngredientWithSchema(typeName, description, schema string, factory IngredientFactory) {
+	ingredientRegistryMu.Lock()
+	defer ingredientRegistryMu.Unlock()
+
+	if factory == nil {
+		panic("RegisterIngredient: factory for " + typeName + " is nil")
+	}
+
+	if schema != "" {
+		if _, err := compileInputSchema(typeName, schema); err != nil {
+			panic("RegisterIngredient: invalid schema for " + typeName + ": " + err.Error())
+		}
+	}
+
+	if _, exists := ingredientRegistry[typeName]; exists {
+		panic("RegisterIngredient: ingredient " + typeName + " registered twice")
+	}
+
+	ingredientRegistry[typeName] = RegisteredIngredient{
+		Type:        typeName,
+		Description: description,
+		Schema:      schema,
+		Factory:     factory,
+	}
+}
+
+// Returns all registered ingredients, sorted by type name.
+func RegisteredIngredients() []RegisteredIngredient {
+	ingredientRegistryMu.RLock()
+	defer ingredientRegistryMu.RUnlock()
+
+	ingredients := make([]RegisteredIngredient, 0, len(ingredientRegistry))
+	for _, ingredient := range ingredientRegistry {
+		ingredients = append(ingredients, ingredient)
+	}
+
+	sort.Slice(ingredients, func(i, j int) bool { return ingredients[i].Type < ingredients[j].Type })
+
+	return ingredients
+}
+
+// Returns the ingredient registered under a type name.
+func GetRegisteredIngredient(typeName string) (RegisteredIngredient, bool) {
+	ingredientRegistryMu.RLock()
+	defer ingredientRegistryMu.RUnlock()
+
+	ingredient, ok := ingredientRegistry[typeName]
+
+	return ingredient, ok
+}
@@ -0,0 +1,176 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// SchemaIngredient is implemented by ingredients publishing a JSON Schema for their inputs,
+// so that recipes can be validated offline, before any state store is opened.
+type SchemaIngredient interface {
+	// Returns the JSON Schema of the inputs.
+	InputSchema() string
+
+	// Returns the inputs to validate, any value that can be marshalled to JSON.
+	Inputs() interface{}
+}
+
+// A single problem found validating inputs against a JSON Schema.
+type inputProblem struct {
+	pointer string // The JSON pointer to the offending input, e.g. "/rules/0/port".
+	message string // What is wrong with it.
+}
+
+// Validates the inputs of all ingredients of the recipes against their JSON Schemas, and runs the Validate hook
+// of all ValidatingIngredients. All problems are returned at once as ConfigErrors, with paths like
+// "recipe/ingredient.inputs.size".
+// Recipe and ingredient names must be unique across all recipes, including included ones.
+func ValidateRecipes(recipes []Recipe) error {
+	errs := ConfigErrors{}
+	recipeNames := map[string]bool{}
+	ingredientNames := map[string]string{}
+
+	for _, recipe := range flattenRecipes(recipes) {
+		if recipeNames[recipe.Name()] {
+			errs = append(errs, &ConfigError{Path: recipe.Name(), Err: errors.New("recipe name is used twice")})
+		}
+
+		recipeNames[recipe.Name()] = true
+
+		for i, ingredient := range recipe.Ingredients() {
+			if name := IngredientName(ingredient); name != "" {
+				if other, ok := ingredientNames[name]; ok {
+					errs = append(errs, &ConfigError{
+						Path: ingredientPath(recipe, i, ingredient),
+						Err:  fmt.Errorf("ingredient name is already used in recipe %s", other),
+					})
+				} else {
+					ingredientNames[name] = recipe.Name()
+				}
+			}
+
+			if validating, ok := ingredientAs[ValidatingIngredient](ingredient); ok {
+				if err := validating.Validate(); err != nil {
+					errs = append(errs, &ConfigError{Path: ingredientPath(recipe, i, ingredient), Err: err})
+				}
+			}
+
+			schemaIngredient, ok := ingredientAs[SchemaIngredient](ingredient)
+			if !ok || schemaIngredient.InputSchema() == "" {
+				continue
+			}
+
+			path := ingredientPath(recipe, i, ingredient) + ".inputs"
+
+			problems, err := validateInputs(path, schemaIngredient.InputSchema(), schemaIngredient.Inputs())
+			if err != nil {
+				errs = append(errs, &ConfigError{Path: path, Err: err})
+
+				continue
+			}
+
+			for _, problem := range problems {
+				errs = append(errs, &ConfigError{
+					Path: path + pointerToPath(problem.pointer),
+					Err:  errors.New(problem.message),
+				})
+			}
+		}
+	}
+
+	return errs.OrNil()
+}
+
+// Validates the inputs against a JSON Schema and returns all problems found.
+// An error is returned if the schema or the inputs themselves are unusable.
+func validateInputs(name, schema string, inputs interface{}) ([]inputProblem, error) {
+	compiled, err := compileInputSchema(name, schema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid input schema: %w", err)
+	}
+
+	// The validator only knows the types of decoded JSON, so bring the inputs into that shape.
+	encoded, err := json.Marshal(inputs)
+	if err != nil {
+		return nil, fmt.Errorf("inputs cannot be validated: %w", err)
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		return nil, fmt.Errorf("inputs cannot be validated: %w", err)
+	}
+
+	err = compiled.Validate(decoded)
+	if err == nil {
+		return nil, nil
+	}
+
+	validationErr := &jsonschema.ValidationError{}
+	if !errors.As(err, &validationErr) {
+		return nil, err
+	}
+
+	return collectInputProblems(validationErr, nil), nil
+}
+
+// Compiles an input schema, keeping annotations like descriptions and defaults for the recipe descriptions.
+func compileInputSchema(name, schema string) (*jsonschema.Schema, error) {
+	location := "cloudprism:///" + url.PathEscape(name) + ".json"
+
+	compiler := jsonschema.NewCompiler()
+	compiler.ExtractAnnotations = true
+
+	if err := compiler.AddResource(location, strings.NewReader(schema)); err != nil {
+		return nil, err
+	}
+
+	return compiler.Compile(location)
+}
+
+// Flattens the tree of validation errors into its leaves, which describe the actual problems.
+func collectInputProblems(err *jsonschema.ValidationError, problems []inputProblem) []inputProblem {
+	if len(err.Causes) == 0 {
+		return append(problems, inputProblem{pointer: err.InstanceLocation, message: err.Message})
+	}
+
+	for _, cause := range err.Causes {
+		problems = collectInputProblems(cause, problems)
+	}
+
+	return problems
+}
+
+// Turns a JSON pointer like "/rules/0/port" into a path like ".rules[0].port".
+func pointerToPath(pointer string) string {
+	path := ""
+
+	for _, token := range pointerTokens(pointer) {
+		if _, err := strconv.Atoi(token); err == nil {
+			path += "[" + token + "]"
+		} else {
+			path += "." + token
+		}
+	}
+
+	return path
+}
+
+// Splits a JSON pointer into its unescaped tokens.
+func pointerTokens(pointer string) []string {
+	if pointer == "" || pointer == "/" {
+		return nil
+	}
+
+	tokens := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+
+	return tokens
+}
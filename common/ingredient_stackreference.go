@@ -0,0 +1,299 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// The type name of the stack reference ingredient in rapid.yaml.
+const stackReferenceIngredientType = "cloudprism:stack-reference"
+
+// StackOutputReader reads the outputs of the stack of an application environment.
+type StackOutputReader func(app Application, env ApplicationEnvironment) (auto.OutputMap, error)
+
+// StackReferencingIngredient is implemented by ingredients reading the outputs of other stacks.
+// SetStackOutputReader is called before Upsert.
+type StackReferencingIngredient interface {
+	SetStackOutputReader(reader StackOutputReader)
+}
+
+// StackReferenceIngredient references the outputs of another application environment, e.g. the VPC IDs exported
+// by a network stack. The referenced stack is read from its own state store by the reader of the chef.
+type StackReferenceIngredient struct {
+	name            string                 // The name of the ingredient.
+	app             Application            // The referenced application.
+	env             ApplicationEnvironment // The referenced application environment.
+	requiredOutputs []string               // The outputs that must exist in the referenced stack.
+	reader          StackOutputReader      // Reads the outputs of the referenced stack.
+	outputs         auto.OutputMap         // The outputs of the referenced stack, once upserted.
+}
+
+// Returns a reader of the outputs of application environments, each read from its own state store configured by
+// cfg. The state stores are only read, with the secrets provider of the options.
+func GetStackOutputReader(cfg StateStoreConfig, opts ...StateStoreOption) StackOutputReader {
+	return func(app Application, env ApplicationEnvironment) (auto.OutputMap, error) {
+		store, err := GetStateStore(cfg, app, env, append(opts, WithReadOnly())...)
+		if err != nil {
+			return nil, err
+		}
+
+		ctx := context.Background()
+		chef := &defaultChef{app: app, env: env, store: store}
+
+		stack, err := chef.getStack(ctx, false)
+		if err != nil {
+			return nil, err
+		}
+
+		outputs, err := stack.Outputs(ctx)
+		if err != nil {
+			log.WithFields(chef.fields()).WithError(err).Error("DefaultChef reading outputs failed")
+
+			return nil, err
+		}
+
+		return outputs, nil
+	}
+}
+
+// Passes the reader to all StackReferencingIngredients before they are upserted.
+func WithStackOutputReader(reader StackOutputReader) ProgramOption {
+	return func(opts *programOptions) {
+		opts.reader = reader
+	}
+}
+
+// Returns an ingredient referencing the outputs of another application environment.
+// Upserting it fails if any of the required outputs is missing in the referenced stack.
+func GetStackReferenceIngredient(name string, app Application, env ApplicationEnvironment, requiredOutputs ...string) *StackReferenceIngredient {
+	return &StackReferenceIngredient{
+		name:            name,
+		app:             app,
+		env:             env,
+		requiredOutputs: requiredOutputs,
+	}
+}
+
+// Returns the name of the referenced stack, as project/stack.
+func (sr *StackReferenceIngredient) StackName() string {
+	return sr.app.ID() + "/" + sr.env.ID()
+}
+
+// SetStackOutputReader implements StackReferencingIngredient.
+func (sr *StackReferenceIngredient) SetStackOutputReader(reader StackOutputReader) {
+	sr.reader = reader
+}
+
+// Upsert implements Ingredient, reading the referenced stack and checking the required outputs right away.
+func (sr *StackReferenceIngredient) Upsert(*pulumi.Context) error {
+	if sr.reader == nil {
+		return fmt.Errorf("stack reference %s: no reader of stack outputs configured", sr.name)
+	}
+
+	outputs, err := sr.reader(sr.app, sr.env)
+	if err != nil {
+		return fmt.Errorf("stack reference %s: reading stack %s: %w", sr.name, sr.StackName(), err)
+	}
+
+	sr.outputs = outputs
+
+	missing := []string{}
+
+	for _, output := range sr.requiredOutputs {
+		if _, ok := outputs[output]; !ok {
+			missing = append(missing, output)
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+
+		return fmt.Errorf("stack reference %s: outputs %s missing in stack %s", sr.name, strings.Join(missing, ", "), sr.StackName())
+	}
+
+	return nil
+}
+
+// Result implements Ingredient, returning the ingredient itself once upserted, for its typed accessors.
+func (sr *StackReferenceIngredient) Result() interface{} {
+	if sr.outputs == nil {
+		return nil
+	}
+
+	return sr
+}
+
+// TypedResult implements TypedIngredient.
+func (sr *StackReferenceIngredient) TypedResult() *StackReferenceIngredient {
+	return sr
+}
+
+// Name implements NamedIngredient.
+func (sr *StackReferenceIngredient) Name() string {
+	return sr.name
+}
+
+// Returns an output of the referenced stack, or an error if it does not exist.
+func (sr *StackReferenceIngredient) Output(name string) (pulumi.AnyOutput, error) {
+	value, err := sr.require(name)
+	if err != nil {
+		return pulumi.AnyOutput{}, err
+	}
+
+	return referencedOutput(pulumi.Any(value.Value), value.Secret).(pulumi.AnyOutput), nil
+}
+
+// Returns a string output of the referenced stack, or an error if it does not exist or is no string.
+func (sr *StackReferenceIngredient) StringOutput(name string) (pulumi.StringOutput, error) {
+	value, err := sr.require(name)
+	if err != nil {
+		return pulumi.StringOutput{}, err
+	}
+
+	s, ok := value.Value.(string)
+	if !ok {
+		return pulumi.StringOutput{}, sr.typeError(name, "a string", value)
+	}
+
+	return referencedOutput(pulumi.String(s), value.Secret).(pulumi.StringOutput), nil
+}
+
+// Returns an integer output of the referenced stack, or an error if it does not exist or is no integer.
+func (sr *StackReferenceIngredient) IntOutput(name string) (pulumi.IntOutput, error) {
+	value, err := sr.require(name)
+	if err != nil {
+		return pulumi.IntOutput{}, err
+	}
+
+	// Outputs are read as JSON, so numbers are floats.
+	f, ok := value.Value.(float64)
+	if !ok || f != math.Trunc(f) {
+		return pulumi.IntOutput{}, sr.typeError(name, "an integer", value)
+	}
+
+	return referencedOutput(pulumi.Int(int(f)), value.Secret).(pulumi.IntOutput), nil
+}
+
+// Returns a string list output of the referenced stack, or an error if it does not exist or is no string list.
+func (sr *StackReferenceIngredient) StringArrayOutput(name string) (pulumi.StringArrayOutput, error) {
+	value, err := sr.require(name)
+	if err != nil {
+		return pulumi.StringArrayOutput{}, err
+	}
+
+	list, ok := value.Value.([]interface{})
+	if !ok {
+		return pulumi.StringArrayOutput{}, sr.typeError(name, "a string list", value)
+	}
+
+	strs := make([]string, 0, len(list))
+
+	for _, item := range list {
+		s, ok := item.(string)
+		if !ok {
+			return pulumi.StringArrayOutput{}, sr.typeError(name, "a string list", value)
+		}
+
+		strs = append(strs, s)
+	}
+
+	return referencedOutput(pulumi.ToStringArray(strs), value.Secret).(pulumi.StringArrayOutput), nil
+}
+
+// Returns a string map output of the referenced stack, or an error if it does not exist or is no string map.
+func (sr *StackReferenceIngredient) StringMapOutput(name string) (pulumi.StringMapOutput, error) {
+	value, err := sr.require(name)
+	if err != nil {
+		return pulumi.StringMapOutput{}, err
+	}
+
+	m, ok := value.Value.(map[string]interface{})
+	if !ok {
+		return pulumi.StringMapOutput{}, sr.typeError(name, "a string map", value)
+	}
+
+	strs := make(map[string]string, len(m))
+
+	for key, item := range m {
+		s, ok := item.(string)
+		if !ok {
+			return pulumi.StringMapOutput{}, sr.typeError(name, "a string map", value)
+		}
+
+		strs[key] = s
+	}
+
+	return referencedOutput(pulumi.ToStringMap(strs), value.Secret).(pulumi.StringMapOutput), nil
+}
+
+// Returns an output of the referenced stack, or an error if it does not exist.
+func (sr *StackReferenceIngredient) require(name string) (auto.OutputValue, error) {
+	if sr.outputs == nil {
+		return auto.OutputValue{}, fmt.Errorf("stack reference %s: output %s requested before the reference was upserted", sr.name, name)
+	}
+
+	value, ok := sr.outputs[name]
+	if !ok {
+		return auto.OutputValue{}, fmt.Errorf("stack reference %s: output %s missing in stack %s", sr.name, name, sr.StackName())
+	}
+
+	return value, nil
+}
+
+func (sr *StackReferenceIngredient) typeError(name, expected string, value auto.OutputValue) error {
+	if value.Secret {
+		return fmt.Errorf("stack reference %s: output %s of stack %s is not %s", sr.name, name, sr.StackName(), expected)
+	}
+
+	return fmt.Errorf("stack reference %s: output %s of stack %s is not %s: %v", sr.name, name, sr.StackName(), expected, value.Value)
+}
+
+// Returns the input as output, secret if the referenced output is.
+func referencedOutput(input pulumi.Input, secret bool) pulumi.Output {
+	if secret {
+		return pulumi.ToSecret(input)
+	}
+
+	return pulumi.ToOutput(input)
+}
+
+// nolint: gochecknoinits
+func init() {
+	RegisterIngredientWithSchema(stackReferenceIngredientType,
+		"References the outputs of another application environment",
+		`{
+			"type": "object",
+			"required": ["application", "environment"],
+			"additionalProperties": false,
+			"properties": {
+				"application": {"type": "string", "minLength": 1},
+				"environment": {"type": "string", "minLength": 1},
+				"outputs": {"type": "array", "items": {"type": "string"}}
+			}
+		}`,
+		func(name string, properties map[string]interface{}, _ map[string]IngredientDependency) (Ingredient, error) {
+			app, _ := properties["application"].(string)
+			envText, _ := properties["environment"].(string)
+
+			env, err := ParseApplicationEnvironment(envText)
+			if err != nil {
+				return nil, err
+			}
+
+			outputs := []string{}
+
+			list, _ := properties["outputs"].([]interface{})
+			for _, output := range list {
+				outputs = append(outputs, fmt.Sprint(output))
+			}
+
+			return GetStackReferenceIngredient(name, Application(app), env, outputs...), nil
+		})
+}
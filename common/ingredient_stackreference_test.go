@@ -0,0 +1,209 @@
+package common_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"sourcesign.de/cloudprism/common"
+	"sourcesign.de/cloudprism/common/recipetest"
+)
+
+// networkOutputs are the outputs of the referenced network stack.
+// nolint: gochecknoglobals
+var networkOutputs = auto.OutputMap{
+	"vpcId":   {Value: "vpc-1"},
+	"token":   {Value: "s3cr3t", Secret: true},
+	"subnets": {Value: []interface{}{"subnet-a", "subnet-b"}},
+	"tags":    {Value: map[string]interface{}{"Team": "network"}},
+	"zones":   {Value: float64(3)},
+}
+
+// Returns a reader of the network stack of the application "net", recording the stacks read.
+func networkReader(read *[]string) common.StackOutputReader {
+	return func(app common.Application, env common.ApplicationEnvironment) (auto.OutputMap, error) {
+		*read = append(*read, app.ID()+"/"+env.ID())
+
+		if app.ID() != "net" {
+			return nil, errors.New("stack not found")
+		}
+
+		return networkOutputs, nil
+	}
+}
+
+// vpcBucketIngredient registers a bucket tagged with outputs of the network stack.
+type vpcBucketIngredient struct {
+	network *common.StackReferenceIngredient
+}
+
+func (vi *vpcBucketIngredient) Upsert(ctx *pulumi.Context) error {
+	vpc, err := vi.network.StringOutput("vpcId")
+	if err != nil {
+		return err
+	}
+
+	token, err := vi.network.StringOutput("token")
+	if err != nil {
+		return err
+	}
+
+	tags := pulumi.StringMap{"Vpc": vpc, "Token": token}
+	args := &bucketArgs{Tags: tags}
+
+	return ctx.RegisterResource(bucketType, "vpc-bucket", args, &pulumi.CustomResourceState{})
+}
+
+func (vi *vpcBucketIngredient) Result() interface{} {
+	return nil
+}
+
+func TestStackReferenceReadsTheReferencedStack(t *testing.T) {
+	read := []string{}
+	network := common.GetStackReferenceIngredient("network", "net", common.AppEnvProduction, "vpcId", "subnets")
+
+	recipe := common.GetDefaultRecipe("storage")
+	recipe.Append(network, &vpcBucketIngredient{network: network})
+
+	result, err := recipetest.Run([]common.Recipe{recipe},
+		recipetest.WithProgramOptions(common.WithStackOutputReader(networkReader(&read))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(read) != 1 || read[0] != "net/prd" {
+		t.Errorf("expected the stack net/prd to be read once, got %v", read)
+	}
+
+	result.AssertProperty(t, bucketType, "tags.Vpc", "vpc-1")
+	result.AssertProperty(t, bucketType, "tags.Token", "s3cr3t")
+
+	bucket := result.AssertNamedResource(t, bucketType, "vpc-bucket")
+	if !bucket.Inputs["tags"].ContainsSecrets() {
+		t.Error("expected the secret output to stay secret")
+	}
+}
+
+func TestStackReferenceUpsertErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		app     common.Application
+		outputs []string
+		reader  bool
+		wantErr string
+	}{
+		{
+			name:    "missing outputs",
+			app:     "net",
+			outputs: []string{"vpcId", "natIp", "dnsZone"},
+			reader:  true,
+			wantErr: "stack reference network: outputs dnsZone, natIp missing in stack net/prd",
+		},
+		{
+			name:    "unreadable stack",
+			app:     "other",
+			outputs: []string{"vpcId"},
+			reader:  true,
+			wantErr: "stack reference network: reading stack other/prd: stack not found",
+		},
+		{name: "no reader", app: "net", wantErr: "stack reference network: no reader of stack outputs configured"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			network := common.GetStackReferenceIngredient("network", tt.app, common.AppEnvProduction, tt.outputs...)
+
+			opts := []recipetest.Option{}
+			if tt.reader {
+				opts = append(opts, recipetest.WithProgramOptions(common.WithStackOutputReader(networkReader(&[]string{}))))
+			}
+
+			recipe := common.GetDefaultRecipe("storage")
+			recipe.Append(network)
+
+			_, err := recipetest.Run([]common.Recipe{recipe}, opts...)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestStackReferenceOutputTypes(t *testing.T) {
+	network := common.GetStackReferenceIngredient("network", "net", common.AppEnvProduction)
+
+	if _, err := network.StringOutput("vpcId"); err == nil || !strings.Contains(err.Error(), "before the reference was upserted") {
+		t.Errorf("expected outputs to be refused before the upsert, got %v", err)
+	}
+
+	network.SetStackOutputReader(networkReader(&[]string{}))
+
+	if err := network.Upsert(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		output  func() error
+		wantErr string
+	}{
+		{name: "string", output: func() error { _, err := network.StringOutput("vpcId"); return err }},
+		{name: "int", output: func() error { _, err := network.IntOutput("zones"); return err }},
+		{name: "string array", output: func() error { _, err := network.StringArrayOutput("subnets"); return err }},
+		{name: "string map", output: func() error { _, err := network.StringMapOutput("tags"); return err }},
+		{name: "any", output: func() error { _, err := network.Output("tags"); return err }},
+		{
+			name:    "missing",
+			output:  func() error { _, err := network.StringOutput("natIp"); return err },
+			wantErr: "output natIp missing in stack net/prd",
+		},
+		{
+			name:    "not a string",
+			output:  func() error { _, err := network.StringOutput("subnets"); return err },
+			wantErr: "output subnets of stack net/prd is not a string",
+		},
+		{
+			name:    "not an int",
+			output:  func() error { _, err := network.IntOutput("vpcId"); return err },
+			wantErr: "output vpcId of stack net/prd is not an integer: vpc-1",
+		},
+		{
+			name:    "secret not an int",
+			output:  func() error { _, err := network.IntOutput("token"); return err },
+			wantErr: "output token of stack net/prd is not an integer",
+		},
+		{
+			name:    "not a string array",
+			output:  func() error { _, err := network.StringArrayOutput("tags"); return err },
+			wantErr: "is not a string list",
+		},
+		{
+			name:    "not a string map",
+			output:  func() error { _, err := network.StringMapOutput("subnets"); return err },
+			wantErr: "is not a string map",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.output()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+
+			if strings.Contains(err.Error(), "s3cr3t") {
+				t.Errorf("expected the secret value not to be reported, got %v", err)
+			}
+		})
+	}
+}
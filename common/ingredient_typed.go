@@ -0,0 +1,122 @@
+package common
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// TypedIngredient is an ingredient whose result has a type known at compile time.
+type TypedIngredient[T any] interface {
+	Ingredient
+
+	// Returns the result, like Result, but typed.
+	TypedResult() T
+}
+
+type funcIngredient[T any] struct {
+	name     string                               // The name of the ingredient.
+	upsert   func(ctx *pulumi.Context) (T, error) // Creates the resources and returns the result.
+	result   T                                    // The result of the last Upsert.
+	upserted bool                                 // True once Upsert succeeded.
+}
+
+// Returns a typed ingredient from a function creating its resources and returning its result.
+func GetTypedIngredient[T any](name string, upsert func(ctx *pulumi.Context) (T, error)) TypedIngredient[T] {
+	return &funcIngredient[T]{
+		name:   name,
+		upsert: upsert,
+	}
+}
+
+// Upsert implements Ingredient.
+func (fi *funcIngredient[T]) Upsert(ctx *pulumi.Context) error {
+	result, err := fi.upsert(ctx)
+	if err != nil {
+		return err
+	}
+
+	fi.result = result
+	fi.upserted = true
+
+	return nil
+}
+
+// Result implements Ingredient, returning nil until Upsert succeeded.
+func (fi *funcIngredient[T]) Result() interface{} {
+	if !fi.upserted {
+		return nil
+	}
+
+	return fi.result
+}
+
+// TypedResult implements TypedIngredient.
+func (fi *funcIngredient[T]) TypedResult() T {
+	return fi.result
+}
+
+// Name implements NamedIngredient.
+func (fi *funcIngredient[T]) Name() string {
+	return fi.name
+}
+
+// Returns the result of an ingredient as T. Instead of panicking like a failed type assertion,
+// a descriptive error is returned if the ingredient has no result yet or a result of another type.
+func ResultOf[T any](ingredient Ingredient) (T, error) {
+	var zero T
+
+	if ingredient == nil {
+		return zero, fmt.Errorf("result of type %s requested from a nil ingredient", typeName[T]())
+	}
+
+	if typed, ok := ingredientAs[TypedIngredient[T]](ingredient); ok {
+		if ingredient.Result() == nil {
+			return zero, fmt.Errorf("%s has no result, is it upserted yet?", describeIngredient(ingredient))
+		}
+
+		return typed.TypedResult(), nil
+	}
+
+	return resultAs[T](describeIngredient(ingredient), ingredient.Result())
+}
+
+// Returns the result of a dependency, as passed to an IngredientFactory, as T.
+// Like ResultOf, a descriptive error is returned instead of panicking.
+func DependencyResultOf[T any](name string, dependency IngredientDependency) (T, error) {
+	var zero T
+
+	if dependency == nil {
+		return zero, fmt.Errorf("dependency %q is missing", name)
+	}
+
+	return resultAs[T](fmt.Sprintf("dependency %q", name), dependency())
+}
+
+func resultAs[T any](description string, result interface{}) (T, error) {
+	var zero T
+
+	if result == nil {
+		return zero, fmt.Errorf("%s has no result, is it upserted yet?", description)
+	}
+
+	typed, ok := result.(T)
+	if !ok {
+		return zero, fmt.Errorf("%s has a result of type %T, not %s", description, result, typeName[T]())
+	}
+
+	return typed, nil
+}
+
+func describeIngredient(ingredient Ingredient) string {
+	if name := IngredientName(ingredient); name != "" {
+		return fmt.Sprintf("ingredient %q", name)
+	}
+
+	return fmt.Sprintf("ingredient %T", ingredient)
+}
+
+func typeName[T any]() string {
+	return reflect.TypeOf((*T)(nil)).Elem().String()
+}
@@ -0,0 +1,149 @@
+package common
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// resultIngredient is an untyped ingredient with a fixed result.
+type resultIngredient struct {
+	result interface{}
+}
+
+func (ri resultIngredient) Upsert(*pulumi.Context) error { return nil }
+func (ri resultIngredient) Result() interface{}          { return ri.result }
+
+// Returns a typed ingredient named "bucket" with the result "my-bucket", upserted if upsert is true.
+func bucketNameIngredient(t *testing.T, upsert bool) TypedIngredient[string] {
+	ingredient := GetTypedIngredient("bucket", func(*pulumi.Context) (string, error) {
+		return "my-bucket", nil
+	})
+
+	if upsert {
+		if err := ingredient.Upsert(nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return ingredient
+}
+
+func TestResultOf(t *testing.T) {
+	tests := []struct {
+		name       string
+		ingredient Ingredient
+		want       string
+		wantErr    string
+	}{
+		{name: "typed", ingredient: bucketNameIngredient(t, true), want: "my-bucket"},
+		{name: "typed and wrapped", ingredient: &declaredIngredient{Ingredient: bucketNameIngredient(t, true)}, want: "my-bucket"},
+		{name: "untyped", ingredient: resultIngredient{result: "my-bucket"}, want: "my-bucket"},
+		{
+			name:       "typed not yet upserted",
+			ingredient: bucketNameIngredient(t, false),
+			wantErr:    `ingredient "bucket" has no result, is it upserted yet?`,
+		},
+		{
+			name:       "untyped without result",
+			ingredient: resultIngredient{},
+			wantErr:    "ingredient common.resultIngredient has no result, is it upserted yet?",
+		},
+		{
+			name:       "untyped of another type",
+			ingredient: resultIngredient{result: 42},
+			wantErr:    "ingredient common.resultIngredient has a result of type int, not string",
+		},
+		{name: "nil", wantErr: "result of type string requested from a nil ingredient"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResultOf[string](tt.ingredient)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestResultOfAnotherType(t *testing.T) {
+	_, err := ResultOf[int](bucketNameIngredient(t, true))
+
+	want := `ingredient "bucket" has a result of type string, not int`
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("expected an error containing %q, got %v", want, err)
+	}
+}
+
+func TestDependencyResultOf(t *testing.T) {
+	tests := []struct {
+		name       string
+		dependency IngredientDependency
+		want       string
+		wantErr    string
+	}{
+		{name: "result", dependency: func() interface{} { return "my-bucket" }, want: "my-bucket"},
+		{
+			name:       "not yet upserted",
+			dependency: func() interface{} { return nil },
+			wantErr:    `dependency "bucket" has no result, is it upserted yet?`,
+		},
+		{
+			name:       "another type",
+			dependency: func() interface{} { return []string{"my-bucket"} },
+			wantErr:    `dependency "bucket" has a result of type []string, not string`,
+		},
+		{name: "missing", wantErr: `dependency "bucket" is missing`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DependencyResultOf[string]("bucket", tt.dependency)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestResultAsInterface(t *testing.T) {
+	if _, err := resultAs[interface{ Name() string }]("ingredient", resultIngredient{}); err == nil ||
+		!strings.Contains(err.Error(), "has a result of type common.resultIngredient, not interface { Name() string }") {
+		t.Errorf("expected an error naming the interface, got %v", err)
+	}
+
+	named, err := resultAs[NamedIngredient]("ingredient", bucketNameIngredient(t, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if named.Name() != "bucket" {
+		t.Errorf("expected the name bucket, got %q", named.Name())
+	}
+}
@@ -0,0 +1,176 @@
+package common
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// The keys of the tags derived from the application metadata, in addition to TagOwner and TagCostCenter.
+const (
+	TagContact            = "Contact"
+	TagDataClassification = "DataClassification"
+	TagComplianceScope    = "ComplianceScope"
+	TagRepository         = "Repository"
+)
+
+// DataClassification is the sensitivity of the data an application processes.
+type DataClassification string
+
+const (
+	DataPublic       DataClassification = "public"
+	DataInternal     DataClassification = "internal"
+	DataConfidential DataClassification = "confidential"
+	DataRestricted   DataClassification = "restricted"
+)
+
+// nolint: gochecknoglobals
+var (
+	complianceScopePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+	scpRepositoryPattern   = regexp.MustCompile(`^[\w.-]+@[\w.-]+:[\w./~-]+$`)
+
+	// The characters AWS allows in tag values.
+	tagValuePattern = regexp.MustCompile(`^[\p{L}\p{Z}\p{N}_.:/=+@-]*$`)
+)
+
+// The longest tag value AWS allows, in characters.
+const maxTagValueLength = 256
+
+// ApplicationMetadata describes an application beyond its name, from the "metadata" section of rapid.yaml.
+type ApplicationMetadata struct {
+	// The team owning the application, e.g. "payments".
+	OwnerTeam string `mapstructure:"ownerTeam" yaml:"ownerTeam"`
+	// How to reach the owner team, an email address or a URL, e.g. of a chat channel.
+	Contact string `mapstructure:"contact" yaml:"contact"`
+	// The cost center the resources are billed to.
+	CostCenter string `mapstructure:"costCenter" yaml:"costCenter"`
+	// The sensitivity of the data the application processes.
+	DataClassification DataClassification `mapstructure:"dataClassification" yaml:"dataClassification"`
+	// The compliance regimes the application falls under, e.g. [gdpr, pci-dss].
+	ComplianceScope []string `mapstructure:"complianceScope" yaml:"complianceScope,omitempty"`
+	// The URL of the source repository.
+	Repository string `mapstructure:"repository" yaml:"repository,omitempty"`
+}
+
+// MetadataIngredient is implemented by ingredients using the application metadata, e.g. to tag, name or protect
+// their resources depending on the data classification.
+type MetadataIngredient interface {
+	SetApplicationMetadata(metadata ApplicationMetadata)
+}
+
+// Validates the metadata. All problems found are returned at once as ConfigErrors.
+func (m ApplicationMetadata) Validate() error {
+	errs := ConfigErrors{}
+	problem := func(key, format string, args ...interface{}) {
+		errs = append(errs, &ConfigError{Path: "metadata." + key, Err: fmt.Errorf(format, args...)})
+	}
+
+	for _, required := range []struct {
+		key   string
+		value string
+	}{
+		{"ownerTeam", m.OwnerTeam},
+		{"contact", m.Contact},
+		{"costCenter", m.CostCenter},
+		{"dataClassification", string(m.DataClassification)},
+	} {
+		if strings.TrimSpace(required.value) == "" {
+			problem(required.key, "is required")
+		}
+	}
+
+	if m.Contact != "" && !isEmailAddress(m.Contact) && !isAbsoluteURL(m.Contact) {
+		problem("contact", "%q is neither an email address nor a URL", m.Contact)
+	}
+
+	switch m.DataClassification {
+	case "", DataPublic, DataInternal, DataConfidential, DataRestricted:
+	default:
+		problem("dataClassification", "unknown classification %q, expected public, internal, confidential or restricted",
+			m.DataClassification)
+	}
+
+	seen := map[string]bool{}
+
+	for i, scope := range m.ComplianceScope {
+		if !complianceScopePattern.MatchString(scope) {
+			problem(fmt.Sprintf("complianceScope[%d]", i), "invalid scope %q, expected lowercase letters, digits and dashes", scope)
+		} else if seen[scope] {
+			problem(fmt.Sprintf("complianceScope[%d]", i), "scope %q is listed twice", scope)
+		}
+
+		seen[scope] = true
+	}
+
+	if m.Repository != "" && !isAbsoluteURL(m.Repository) && !scpRepositoryPattern.MatchString(m.Repository) {
+		problem("repository", "%q is not a repository URL", m.Repository)
+	}
+
+	// The values become tags, e.g. of AWS resources.
+	for _, tag := range []struct {
+		key   string
+		value string
+	}{
+		{"ownerTeam", m.OwnerTeam},
+		{"contact", m.Contact},
+		{"costCenter", m.CostCenter},
+		{"complianceScope", strings.Join(m.ComplianceScope, " ")},
+		{"repository", m.Repository},
+	} {
+		if !tagValuePattern.MatchString(tag.value) {
+			problem(tag.key, "%q contains characters not allowed in tags, only letters, digits, spaces and _.:/=+-@ are",
+				tag.value)
+		} else if length := utf8.RuneCountInString(tag.value); length > maxTagValueLength {
+			problem(tag.key, "is %d characters long, tags allow at most %d", length, maxTagValueLength)
+		}
+	}
+
+	return errs.OrNil()
+}
+
+// Returns true if the application processes confidential or restricted data.
+func (m ApplicationMetadata) Sensitive() bool {
+	return m.DataClassification == DataConfidential || m.DataClassification == DataRestricted
+}
+
+// Returns the tags describing the application, for the standard tags. Unset values are left out.
+func (m ApplicationMetadata) Tags() map[string]string {
+	tags := map[string]string{}
+
+	for key, value := range map[string]string{
+		TagOwner:              m.OwnerTeam,
+		TagContact:            m.Contact,
+		TagCostCenter:         m.CostCenter,
+		TagDataClassification: string(m.DataClassification),
+		TagComplianceScope:    strings.Join(m.ComplianceScope, " "),
+		TagRepository:         m.Repository,
+	} {
+		if value != "" {
+			tags[key] = value
+		}
+	}
+
+	return tags
+}
+
+// Passes the application metadata to all MetadataIngredients before they are upserted.
+func WithApplicationMetadata(metadata ApplicationMetadata) ProgramOption {
+	return func(opts *programOptions) {
+		opts.metadata = &metadata
+	}
+}
+
+func isEmailAddress(text string) bool {
+	address, err := mail.ParseAddress(text)
+
+	return err == nil && address.Address == text
+}
+
+func isAbsoluteURL(text string) bool {
+	parsed, err := url.Parse(text)
+
+	return err == nil && parsed.Scheme != "" && parsed.Host != ""
+}
@@ -0,0 +1,146 @@
+package common
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func validMetadata() ApplicationMetadata {
+	return ApplicationMetadata{
+		OwnerTeam:          "payments",
+		Contact:            "payments@example.com",
+		CostCenter:         "CC-4711",
+		DataClassification: DataConfidential,
+		ComplianceScope:    []string{"gdpr", "pci-dss"},
+		Repository:         "https://git.example.com/payments/shop",
+	}
+}
+
+func TestApplicationMetadataValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(m *ApplicationMetadata)
+		want   []string
+	}{
+		{name: "valid", change: func(*ApplicationMetadata) {}},
+		{name: "contact URL", change: func(m *ApplicationMetadata) { m.Contact = "https://chat.example.com/payments" }},
+		{name: "scp repository", change: func(m *ApplicationMetadata) { m.Repository = "git@git.example.com:payments/shop.git" }},
+		{name: "no repository or scope", change: func(m *ApplicationMetadata) { m.Repository, m.ComplianceScope = "", nil }},
+		{
+			name:   "required values missing",
+			change: func(m *ApplicationMetadata) { *m = ApplicationMetadata{} },
+			want:   []string{"metadata.ownerTeam", "metadata.contact", "metadata.costCenter", "metadata.dataClassification"},
+		},
+		{name: "invalid contact", change: func(m *ApplicationMetadata) { m.Contact = "the payments team" }, want: []string{"metadata.contact"}},
+		{name: "unknown classification", change: func(m *ApplicationMetadata) { m.DataClassification = "secret" }, want: []string{"metadata.dataClassification"}},
+		{
+			name:   "invalid and duplicate scopes",
+			change: func(m *ApplicationMetadata) { m.ComplianceScope = []string{"gdpr", "PCI DSS", "gdpr"} },
+			want:   []string{"metadata.complianceScope[1]", "metadata.complianceScope[2]"},
+		},
+		{name: "invalid repository", change: func(m *ApplicationMetadata) { m.Repository = "payments/shop" }, want: []string{"metadata.repository"}},
+		{
+			name:   "contact URL not allowed in tags",
+			change: func(m *ApplicationMetadata) { m.Contact = "https://chat.example.com/channel?id=payments&tab=1" },
+			want:   []string{"metadata.contact"},
+		},
+		{
+			name:   "repository URL not allowed in tags",
+			change: func(m *ApplicationMetadata) { m.Repository = "https://git.example.com/~payments/shop" },
+			want:   []string{"metadata.repository"},
+		},
+		{name: "scp repository with home directory", change: func(m *ApplicationMetadata) { m.Repository = "git@git.example.com:~/shop.git" }, want: []string{"metadata.repository"}},
+		{name: "owner team not allowed in tags", change: func(m *ApplicationMetadata) { m.OwnerTeam = "payments (EU)" }, want: []string{"metadata.ownerTeam"}},
+		{name: "unicode owner team", change: func(m *ApplicationMetadata) { m.OwnerTeam = "Zahlungsverkehr Süd" }},
+		{
+			name:   "cost center too long for tags",
+			change: func(m *ApplicationMetadata) { m.CostCenter = strings.Repeat("ü", 257) },
+			want:   []string{"metadata.costCenter"},
+		},
+		{name: "cost center as long as tags allow", change: func(m *ApplicationMetadata) { m.CostCenter = strings.Repeat("ü", 256) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metadata := validMetadata()
+			tt.change(&metadata)
+
+			err := metadata.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				return
+			}
+
+			problems := ConfigErrors{}
+			if !errors.As(err, &problems) {
+				t.Fatalf("expected ConfigErrors, got %v", err)
+			}
+
+			paths := []string{}
+			for _, problem := range problems {
+				paths = append(paths, problem.Path)
+			}
+
+			if !reflect.DeepEqual(paths, tt.want) {
+				t.Errorf("expected problems at %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestApplicationMetadataTags(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata ApplicationMetadata
+		want     map[string]string
+	}{
+		{name: "empty", want: map[string]string{}},
+		{
+			name:     "all values",
+			metadata: validMetadata(),
+			want: map[string]string{
+				TagOwner:              "payments",
+				TagContact:            "payments@example.com",
+				TagCostCenter:         "CC-4711",
+				TagDataClassification: "confidential",
+				TagComplianceScope:    "gdpr pci-dss",
+				TagRepository:         "https://git.example.com/payments/shop",
+			},
+		},
+		{
+			name:     "unset values left out",
+			metadata: ApplicationMetadata{OwnerTeam: "payments", DataClassification: DataPublic},
+			want:     map[string]string{TagOwner: "payments", TagDataClassification: "public"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.metadata.Tags(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestApplicationMetadataClassification(t *testing.T) {
+	for _, tt := range []struct {
+		classification DataClassification
+		sensitive      bool
+	}{
+		{DataPublic, false},
+		{DataInternal, false},
+		{DataConfidential, true},
+		{DataRestricted, true},
+	} {
+		metadata := ApplicationMetadata{DataClassification: tt.classification}
+		if metadata.Sensitive() != tt.sensitive {
+			t.Errorf("%s: expected sensitive %v", tt.classification, tt.sensitive)
+		}
+	}
+}
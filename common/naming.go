@@ -0,0 +1,159 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// The length of the hash suffix added to truncated names.
+const namingHashLength = 6
+
+// NamingRule describes the names a cloud provider allows for a resource type.
+type NamingRule struct {
+	MinLength           int    // The minimum length, 0 for none.
+	MaxLength           int    // The maximum length.
+	Charset             string // The allowed characters as the content of a regexp character class, e.g. "a-z0-9-".
+	Separator           string // The separator between the parts of a name, empty if no separator is allowed.
+	Lowercase           bool   // True if names are lowercased before the charset is applied.
+	MustStartWithLetter bool   // True if names must start with a letter.
+}
+
+// Naming builds the physical names of resources from the application, the environment, the region and the
+// ingredient, following the rule registered for the resource type. Names are stable: the same inputs always give
+// the same name, and names too long for a resource type are truncated with a short hash of the full name appended.
+type Naming struct {
+	app    Application            // The application.
+	env    ApplicationEnvironment // The application environment.
+	region string                 // The region, empty if names are not region specific.
+}
+
+// NamingIngredient is implemented by ingredients that name their resources with the naming of the chef.
+// SetNaming is called before Upsert.
+type NamingIngredient interface {
+	SetNaming(naming *Naming)
+}
+
+// nolint: gochecknoglobals
+var (
+	namingRegistryMu sync.RWMutex
+	namingRegistry   = map[string]NamingRule{}
+
+	// The rule of resource types without a registered one, safe for most providers.
+	defaultNamingRule = NamingRule{MaxLength: 63, Charset: "a-z0-9-", Separator: "-", Lowercase: true}
+)
+
+// Returns a naming for an application environment. The region may be empty.
+func GetNaming(app Application, env ApplicationEnvironment, region string) *Naming {
+	return &Naming{
+		app:    app,
+		env:    env,
+		region: region,
+	}
+}
+
+// Passes the naming to all NamingIngredients before they are upserted.
+func WithNaming(naming *Naming) ProgramOption {
+	return func(opts *programOptions) {
+		opts.naming = naming
+	}
+}
+
+// Registers the naming rule of a resource type, e.g. "aws:s3/bucket:Bucket".
+// Registering the same resource type twice panics.
+func RegisterNamingRule(resourceType string, rule NamingRule) {
+	namingRegistryMu.Lock()
+	defer namingRegistryMu.Unlock()
+
+	if rule.MaxLength <= namingHashLength+len(rule.Separator) {
+		panic(fmt.Sprintf("RegisterNamingRule: maximum length %d of %s is too short", rule.MaxLength, resourceType))
+	}
+
+	if _, err := regexp.Compile("[" + rule.Charset + "]"); err != nil || rule.Charset == "" {
+		panic("RegisterNamingRule: invalid charset for " + resourceType)
+	}
+
+	if _, exists := namingRegistry[resourceType]; exists {
+		panic("RegisterNamingRule: rule for " + resourceType + " registered twice")
+	}
+
+	namingRegistry[resourceType] = rule
+}
+
+// Returns the naming rule of a resource type, the default rule if none is registered.
+func GetNamingRule(resourceType string) NamingRule {
+	namingRegistryMu.RLock()
+	defer namingRegistryMu.RUnlock()
+
+	if rule, ok := namingRegistry[resourceType]; ok {
+		return rule
+	}
+
+	return defaultNamingRule
+}
+
+// Returns the name of a resource of a type, e.g. "aws:s3/bucket:Bucket", created by an ingredient,
+// like "myapp-prd-eu-central-1-assets".
+func (n *Naming) Name(resourceType, ingredient string) (string, error) {
+	return GetNamingRule(resourceType).apply(n.app.ID(), n.env.ID(), n.region, ingredient)
+}
+
+// Joins the non-empty parts to a name following the rule, truncating it with a hash suffix if it is too long.
+func (r NamingRule) apply(parts ...string) (string, error) {
+	disallowed := regexp.MustCompile("[^" + r.Charset + "]+")
+	cleaned := make([]string, 0, len(parts))
+
+	for _, part := range parts {
+		if r.Lowercase {
+			part = strings.ToLower(part)
+		}
+
+		part = strings.Trim(disallowed.ReplaceAllString(part, r.Separator), r.Separator)
+		if r.Separator != "" {
+			part = regexp.MustCompile(regexp.QuoteMeta(r.Separator)+"{2,}").ReplaceAllString(part, r.Separator)
+		}
+
+		if part != "" {
+			cleaned = append(cleaned, part)
+		}
+	}
+
+	name := strings.Join(cleaned, r.Separator)
+
+	if len(name) > r.MaxLength {
+		sum := sha256.Sum256([]byte(name))
+		hash := hex.EncodeToString(sum[:])[:namingHashLength]
+
+		name = strings.TrimRight(name[:r.MaxLength-namingHashLength-len(r.Separator)], r.Separator) + r.Separator + hash
+	}
+
+	if len(name) < r.MinLength {
+		return "", fmt.Errorf("name %q is shorter than %d characters", name, r.MinLength)
+	}
+
+	if r.MustStartWithLetter && (name == "" || !isASCIILetter(name[0])) {
+		return "", fmt.Errorf("name %q must start with a letter", name)
+	}
+
+	return name, nil
+}
+
+func isASCIILetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// nolint: gochecknoinits
+func init() {
+	RegisterNamingRule("aws:s3/bucket:Bucket", NamingRule{MinLength: 3, MaxLength: 63, Charset: "a-z0-9-", Separator: "-", Lowercase: true})
+	RegisterNamingRule("aws:s3/bucketV2:BucketV2", NamingRule{MinLength: 3, MaxLength: 63, Charset: "a-z0-9-", Separator: "-", Lowercase: true})
+	RegisterNamingRule("aws:iam/role:Role", NamingRule{MaxLength: 64, Charset: "a-zA-Z0-9+=,.@_-", Separator: "-"})
+	RegisterNamingRule("aws:iam/policy:Policy", NamingRule{MaxLength: 128, Charset: "a-zA-Z0-9+=,.@_-", Separator: "-"})
+	RegisterNamingRule("aws:rds/instance:Instance", NamingRule{MaxLength: 63, Charset: "a-z0-9-", Separator: "-", Lowercase: true, MustStartWithLetter: true})
+	RegisterNamingRule("aws:rds/cluster:Cluster", NamingRule{MaxLength: 63, Charset: "a-z0-9-", Separator: "-", Lowercase: true, MustStartWithLetter: true})
+	RegisterNamingRule("aws:lambda/function:Function", NamingRule{MaxLength: 64, Charset: "a-zA-Z0-9_-", Separator: "-"})
+	RegisterNamingRule("azure-native:storage:StorageAccount", NamingRule{MinLength: 3, MaxLength: 24, Charset: "a-z0-9", Lowercase: true})
+	RegisterNamingRule("azure-native:keyvault:Vault", NamingRule{MinLength: 3, MaxLength: 24, Charset: "a-zA-Z0-9-", Separator: "-", MustStartWithLetter: true})
+}
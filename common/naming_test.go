@@ -0,0 +1,68 @@
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNamingRuleApply(t *testing.T) {
+	bucket := NamingRule{MinLength: 3, MaxLength: 63, Charset: "a-z0-9-", Separator: "-", Lowercase: true}
+	storage := NamingRule{MinLength: 3, MaxLength: 24, Charset: "a-z0-9", Lowercase: true}
+	database := NamingRule{MaxLength: 63, Charset: "a-z0-9-", Separator: "-", Lowercase: true, MustStartWithLetter: true}
+
+	tests := []struct {
+		name    string
+		rule    NamingRule
+		parts   []string
+		want    string
+		wantErr string
+	}{
+		{name: "joined", rule: bucket, parts: []string{"shop", "prd", "eu-central-1", "assets"}, want: "shop-prd-eu-central-1-assets"},
+		{name: "empty parts skipped", rule: bucket, parts: []string{"shop", "prd", "", "assets"}, want: "shop-prd-assets"},
+		{name: "lowercased and cleaned", rule: bucket, parts: []string{"My Shop", "prd", "Assets__Bucket"}, want: "my-shop-prd-assets-bucket"},
+		{name: "repeated separators collapsed", rule: bucket, parts: []string{"shop--", "--prd", "a---b"}, want: "shop-prd-a-b"},
+		{name: "no separator", rule: storage, parts: []string{"shop", "prd", "logs"}, want: "shopprdlogs"},
+		{name: "exactly the maximum length", rule: storage, parts: []string{"myshop", "prd", "applicationlogs"}, want: "myshopprdapplicationlogs"},
+		{
+			name:  "truncated with hash",
+			rule:  bucket,
+			parts: []string{"shop", "prd", "eu-central-1", strings.Repeat("assets", 10)},
+			want:  "shop-prd-eu-central-1-assetsassetsassetsassetsassetsasse-1c3918",
+		},
+		{
+			name:  "truncated at a separator",
+			rule:  bucket,
+			parts: []string{"shop", "prd", "eu-central-1", strings.Repeat("a", 33), strings.Repeat("b", 20)},
+			want:  "shop-prd-eu-central-1-" + strings.Repeat("a", 33) + "-3a4313",
+		},
+		{name: "truncated without separator", rule: storage, parts: []string{"myshop", "prd", "applicationlogs2"}, want: "myshopprdapplicati97d856"},
+		{name: "too short", rule: bucket, parts: []string{"a", ""}, wantErr: "shorter than 3"},
+		{name: "must start with letter", rule: database, parts: []string{"1shop", "prd"}, wantErr: "must start with a letter"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.rule.apply(tt.parts...)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an error containing %q, got %q, %v", tt.wantErr, got, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(got) > tt.rule.MaxLength {
+				t.Errorf("name %q is longer than %d", got, tt.rule.MaxLength)
+			}
+
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// ProgramOption configures the Pulumi program deploying the recipes, e.g. with stack-wide transformations.
+type ProgramOption func(*programOptions)
+
+type programOptions struct {
+	tags     map[string]string    // The standard tags of all taggable resources.
+	naming   *Naming              // The naming passed to NamingIngredients, nil if none.
+	metadata *ApplicationMetadata // The metadata passed to MetadataIngredients, nil if none.
+	reader   StackOutputReader    // The reader passed to StackReferencingIngredients, nil if none.
+
+	migrations map[string][]RecipeMigration // The migrations of the recipes, by recipe name.
+	imports    ImportMapping                // The cloud resources to import, by ingredient name.
+	policy     ResourcePolicy               // The default resource options of the environment.
+}
+
+// The state of a running program, shared with its stack transformations.
+type programState struct {
+	options    programOptions // The options of the program.
+	recipe     Recipe         // The recipe being upserted, nil outside of Upsert.
+	ingredient Ingredient     // The ingredient being upserted, nil outside of Upsert.
+
+	imported map[string]map[string]string // The names of the imported resources, by ingredient and mapping key.
+	errs     []error                      // The problems found by the transformations.
+}
+
+// Returns the Pulumi program deploying the recipes, upserting all their ingredients in order, included recipes
+// first. The outputs of every recipe are exported as a map named after the recipe.
+func GetProgram(recipes []Recipe, opts ...ProgramOption) pulumi.RunFunc {
+	options := programOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return func(ctx *pulumi.Context) error {
+		state := &programState{options: options, imported: map[string]map[string]string{}}
+
+		// Imports come first, the transformations changing inputs leave imported resources alone.
+		transformation := chainTransformations(
+			state.importResource, state.tagResource, state.aliasResource, state.applyResourcePolicy,
+		)
+		if err := ctx.RegisterStackTransformation(transformation); err != nil {
+			return err
+		}
+
+		for _, recipe := range flattenRecipes(recipes) {
+			for _, ingredient := range recipe.Ingredients() {
+				if named, ok := ingredientAs[NamingIngredient](ingredient); ok && options.naming != nil {
+					named.SetNaming(options.naming)
+				}
+
+				if aware, ok := ingredientAs[MetadataIngredient](ingredient); ok && options.metadata != nil {
+					aware.SetApplicationMetadata(*options.metadata)
+				}
+
+				if referencing, ok := ingredientAs[StackReferencingIngredient](ingredient); ok && options.reader != nil {
+					referencing.SetStackOutputReader(options.reader)
+				}
+
+				state.recipe, state.ingredient = recipe, ingredient
+				err := ingredient.Upsert(ctx)
+				state.recipe, state.ingredient = nil, nil
+
+				if err != nil {
+					return fmt.Errorf("recipe %s: %w", recipe.Name(), err)
+				}
+			}
+		}
+
+		if err := state.importErrors(); err != nil {
+			return err
+		}
+
+		for _, recipe := range recipes {
+			outputs, err := recipeOutputs(recipe)
+			if err != nil {
+				return err
+			}
+
+			if len(outputs) > 0 {
+				ctx.Export(recipe.Name(), outputs)
+			}
+		}
+
+		return nil
+	}
+}
+
+// Returns a transformation running the transformations in order, each on the result of the previous ones.
+// Pulumi passes the original options to every stack transformation and keeps the options of the last one only,
+// so separately registered transformations would drop the options added by the others, e.g. imports.
+func chainTransformations(transformations ...pulumi.ResourceTransformation) pulumi.ResourceTransformation {
+	return func(args *pulumi.ResourceTransformationArgs) *pulumi.ResourceTransformationResult {
+		current := *args
+		changed := false
+
+		for _, transformation := range transformations {
+			if result := transformation(&current); result != nil {
+				current.Props, current.Opts = result.Props, result.Opts
+				changed = true
+			}
+		}
+
+		if !changed {
+			return nil
+		}
+
+		return &pulumi.ResourceTransformationResult{Props: current.Props, Opts: current.Opts}
+	}
+}
@@ -0,0 +1,38 @@
+package common_test
+
+import (
+	"testing"
+
+	"sourcesign.de/cloudprism/common"
+	"sourcesign.de/cloudprism/common/recipetest"
+)
+
+// metadataIngredient creates buckets named after the owner team of the application.
+type metadataIngredient struct {
+	bucketsIngredient
+	metadata common.ApplicationMetadata
+}
+
+func (mi *metadataIngredient) SetApplicationMetadata(metadata common.ApplicationMetadata) {
+	mi.metadata = metadata
+	mi.names = []string{metadata.OwnerTeam + "-reports"}
+}
+
+func TestProgramPassesApplicationMetadata(t *testing.T) {
+	ingredient := &metadataIngredient{}
+	recipe := common.GetDefaultRecipe("reports")
+	recipe.Append(ingredient)
+
+	metadata := common.ApplicationMetadata{OwnerTeam: "payments", DataClassification: common.DataRestricted}
+
+	result, err := recipetest.Run([]common.Recipe{recipe}, recipetest.WithProgramOptions(common.WithApplicationMetadata(metadata)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ingredient.metadata.DataClassification != common.DataRestricted {
+		t.Errorf("expected the ingredient to get the metadata, got %+v", ingredient.metadata)
+	}
+
+	result.AssertNamedResource(t, bucketType, "payments-reports")
+}
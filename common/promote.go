@@ -0,0 +1,145 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+)
+
+const (
+	// The stack tag recording the deployment target, i.e. the Git hash, of the last update.
+	deploymentTargetTag = "cloudprism:target"
+
+	// The stack tag recording the environments the deployment target was promoted through, like "dev>int>prd".
+	promotionChainTag = "cloudprism:promotion"
+
+	// The separator of the environments of a promotion chain.
+	promotionChainSeparator = ">"
+
+	// The longest value of a stack tag.
+	maxStackTagValueLength = 256
+)
+
+// Deploys the deployment target of the chef, e.g. the Git hash of the working tree.
+// The target is recorded in the message of every update, and is what promotions deploy.
+func WithDeploymentTarget(target string) ChefOption {
+	return func(co *chefOptions) {
+		co.target = target
+	}
+}
+
+// Promote implements Chef. The deployment target of the chef must be the one currently deployed in the source
+// environment, and the last update of the source environment must have succeeded. The source state store is the
+// one of the source environment, which is only read.
+func (dc *defaultChef) Promote(from ApplicationEnvironment, source StateStore) error {
+	if err := checkReadOnly(dc.store, "Promote to "+dc.stackName()); err != nil {
+		return err
+	}
+
+	if from == dc.env {
+		return fmt.Errorf("cannot promote %s to itself", from.ID())
+	}
+
+	ctx := context.Background()
+	sourceChef := &defaultChef{app: dc.app, env: from, store: source, recipes: dc.recipes, options: dc.options}
+
+	stack, err := sourceChef.getStack(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	history, err := stack.History(ctx, 0, 0)
+	if err != nil {
+		log.WithFields(sourceChef.fields()).WithError(err).Error("DefaultChef reading history failed")
+
+		return err
+	}
+
+	chain, err := promotionChain(from.ID(), history, dc.options.target)
+	if err != nil {
+		return err
+	}
+
+	log.WithFields(dc.fields()).WithFields(log.Fields{
+		"from":   from.ID(),
+		"target": dc.options.target,
+		"chain":  strings.Join(chain, promotionChainSeparator),
+	}).Info("Promoting deployment target")
+
+	return dc.up(append(chain, dc.env.ID()))
+}
+
+// Returns the environments the deployment target was promoted through to the source environment, according to the
+// history of the source stack, newest update first. An error is returned if the last update of the source did not
+// succeed or deployed another target, or if the source was destroyed since.
+func promotionChain(source string, history []auto.UpdateSummary, target string) ([]string, error) {
+	for _, update := range history {
+		if update.Kind == string(apitype.DestroyUpdate) {
+			return nil, fmt.Errorf("%s was destroyed, nothing to promote", source)
+		}
+
+		if update.Kind != string(apitype.UpdateUpdate) {
+			continue
+		}
+
+		if update.Result != string(apitype.SucceededResult) {
+			return nil, fmt.Errorf("the last update of %s (version %d) did not succeed, refusing to promote it", source, update.Version)
+		}
+
+		record := parseDeploymentMessage(update.Message)
+		if record == nil || record.Target == "" {
+			return nil, fmt.Errorf("%s records no deployment target, update it before promoting it", source)
+		}
+
+		if record.Target != target {
+			return nil, fmt.Errorf("%s runs %s, but the working tree is at %s, check out %s with a clean working tree",
+				source, record.Target, target, record.Target)
+		}
+
+		if len(record.Promotion) == 0 {
+			return []string{source}, nil
+		}
+
+		return record.Promotion, nil
+	}
+
+	return nil, fmt.Errorf("%s was never updated, nothing to promote", source)
+}
+
+// Returns the value of the stack tag recording a promotion chain, like "dev>int>prd". The oldest environments of
+// chains too long for a tag are dropped.
+func promotionChainTagValue(chain []string) string {
+	value := strings.Join(chain, promotionChainSeparator)
+
+	for len(value) > maxStackTagValueLength && len(chain) > 1 {
+		chain = chain[1:]
+		value = strings.Join(chain, promotionChainSeparator)
+	}
+
+	return value
+}
+
+// Mirrors the deployment target and the promotion chain of an update into the tags of the stack, where the state
+// store keeps them. Without a deployment target, the tagged one is kept. The update already succeeded, so failures
+// are logged only.
+func (dc *defaultChef) recordDeployment(ctx context.Context, stack auto.Stack, chain []string) {
+	if !stackTagsSupported(stack) {
+		return
+	}
+
+	tags := map[string]string{promotionChainTag: promotionChainTagValue(chain)}
+
+	if dc.options.target != "" {
+		tags[deploymentTargetTag] = dc.options.target
+	}
+
+	for _, name := range sortedKeys(tags) {
+		if err := stack.SetTag(ctx, name, tags[name]); err != nil {
+			log.WithFields(dc.fields()).WithField("tag", name).WithError(err).Warn("DefaultChef recording deployment failed")
+		}
+	}
+}
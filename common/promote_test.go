@@ -0,0 +1,104 @@
+package common
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+)
+
+func TestPromotionChain(t *testing.T) {
+	deployed := `cloudprism: {"target":"abc123","promotion":["dev","int"]}`
+
+	tests := []struct {
+		name    string
+		history []auto.UpdateSummary
+		want    []string
+		wantErr string
+	}{
+		{
+			name:    "promoted before",
+			history: []auto.UpdateSummary{{Kind: "update", Result: "succeeded", Message: deployed}},
+			want:    []string{"dev", "int"},
+		},
+		{
+			name: "updated directly",
+			history: []auto.UpdateSummary{
+				{Kind: "refresh", Result: "succeeded"},
+				{Kind: "update", Result: "succeeded", Message: `cloudprism: {"target":"abc123"}`},
+			},
+			want: []string{"int"},
+		},
+		{
+			name: "last update failed",
+			history: []auto.UpdateSummary{
+				{Kind: "update", Result: "failed", Version: 4, Message: deployed},
+				{Kind: "update", Result: "succeeded", Version: 3, Message: deployed},
+			},
+			wantErr: "the last update of int (version 4) did not succeed",
+		},
+		{
+			name: "destroyed",
+			history: []auto.UpdateSummary{
+				{Kind: "destroy", Result: "succeeded"},
+				{Kind: "update", Result: "succeeded", Message: deployed},
+			},
+			wantErr: "int was destroyed",
+		},
+		{
+			name:    "other target",
+			history: []auto.UpdateSummary{{Kind: "update", Result: "succeeded", Message: `cloudprism: {"target":"def456"}`}},
+			wantErr: "int runs def456, but the working tree is at abc123",
+		},
+		{
+			name:    "no target recorded",
+			history: []auto.UpdateSummary{{Kind: "update", Result: "succeeded", Message: "pulumi up by hand"}},
+			wantErr: "int records no deployment target",
+		},
+		{name: "never updated", wantErr: "int was never updated"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain, err := promotionChain("int", tt.history, "abc123")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(chain, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, chain)
+			}
+		})
+	}
+}
+
+func TestPromotionChainTagValue(t *testing.T) {
+	long := strings.Repeat("e", 125)
+
+	tests := []struct {
+		name  string
+		chain []string
+		want  string
+	}{
+		{name: "short", chain: []string{"dev", "int", "prd"}, want: "dev>int>prd"},
+		{name: "oldest dropped", chain: []string{"dev", long, long, "prd"}, want: long + ">" + long + ">prd"},
+		{name: "single too long", chain: []string{strings.Repeat("e", 300)}, want: strings.Repeat("e", 300)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := promotionChainTagValue(tt.chain); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
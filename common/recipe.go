@@ -1,7 +1,89 @@
 package common
 
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
 type Recipe interface {
 	Name() string
 	Ingredients() []Ingredient
 	Append(ingredients ...Ingredient)
 }
+
+// NestingRecipe is implemented by recipes including other recipes, e.g. a web service including a database.
+// Included recipes are named with the name of the including recipe as prefix, see NestedRecipeName.
+type NestingRecipe interface {
+	Recipe
+
+	// Returns the included recipes.
+	Recipes() []Recipe
+
+	// Includes recipes, their ingredients are upserted before the ones of the including recipe.
+	Include(recipes ...Recipe)
+}
+
+// Returns the name of a recipe included by another, prefixed with the name of the including recipe.
+func NestedRecipeName(parent, name string) string {
+	return parent + "-" + name
+}
+
+// Returns the recipes and all recipes included by them, recursively, every included recipe before its parent.
+func flattenRecipes(recipes []Recipe) []Recipe {
+	flattened := make([]Recipe, 0, len(recipes))
+
+	for _, recipe := range recipes {
+		if nesting, ok := recipe.(NestingRecipe); ok {
+			flattened = append(flattened, flattenRecipes(nesting.Recipes())...)
+		}
+
+		flattened = append(flattened, recipe)
+	}
+
+	return flattened
+}
+
+// Returns the outputs of a recipe: the outputs of its OutputIngredients, and a nested map per included recipe,
+// keyed by the name of the included recipe without the prefix of its parent.
+func recipeOutputs(recipe Recipe) (pulumi.Map, error) {
+	outputs := pulumi.Map{}
+
+	for _, ingredient := range recipe.Ingredients() {
+		outputting, ok := ingredientAs[OutputIngredient](ingredient)
+		if !ok {
+			continue
+		}
+
+		for key, value := range outputting.Outputs() {
+			if _, exists := outputs[key]; exists {
+				return nil, fmt.Errorf("recipe %s: output %q is exported twice", recipe.Name(), key)
+			}
+
+			outputs[key] = value
+		}
+	}
+
+	nesting, ok := recipe.(NestingRecipe)
+	if !ok {
+		return outputs, nil
+	}
+
+	for _, included := range nesting.Recipes() {
+		key := strings.TrimPrefix(included.Name(), NestedRecipeName(recipe.Name(), ""))
+
+		if _, exists := outputs[key]; exists {
+			return nil, fmt.Errorf("recipe %s: output %q of the included recipe clashes with another output", recipe.Name(), key)
+		}
+
+		nested, err := recipeOutputs(included)
+		if err != nil {
+			return nil, err
+		}
+
+		outputs[key] = nested
+	}
+
+	return outputs, nil
+}
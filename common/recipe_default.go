@@ -3,6 +3,7 @@ package common
 type defaultRecipe struct {
 	name        string
 	ingredients []Ingredient
+	recipes     []Recipe
 }
 
 // Append implements Recipe.
@@ -20,9 +21,20 @@ func (dr *defaultRecipe) Name() string {
 	return dr.name
 }
 
+// Include implements NestingRecipe.
+func (dr *defaultRecipe) Include(recipes ...Recipe) {
+	dr.recipes = append(dr.recipes, recipes...)
+}
+
+// Recipes implements NestingRecipe.
+func (dr *defaultRecipe) Recipes() []Recipe {
+	return dr.recipes
+}
+
 func GetDefaultRecipe(name string) Recipe {
 	return &defaultRecipe{
 		name:        name,
 		ingredients: make([]Ingredient, 0),
+		recipes:     make([]Recipe, 0),
 	}
 }
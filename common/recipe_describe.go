@@ -0,0 +1,271 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// DocumentedIngredient is implemented by ingredients documenting themselves, for the recipe descriptions.
+type DocumentedIngredient interface {
+	// Returns a one-line description.
+	Description() string
+
+	// Returns the names of the outputs, i.e. the keys of Outputs, known before Upsert.
+	OutputNames() []string
+}
+
+// RecipeDescription documents a registered recipe, generated from the recipe itself.
+type RecipeDescription struct {
+	Name        string                  `json:"name"`
+	Description string                  `json:"description"`
+	Ingredients []IngredientDescription `json:"ingredients"`
+}
+
+// IngredientDescription documents an ingredient of a recipe.
+type IngredientDescription struct {
+	Recipe      string             `json:"recipe"`
+	Name        string             `json:"name"`
+	Type        string             `json:"type"`
+	Description string             `json:"description,omitempty"`
+	Inputs      []InputDescription `json:"inputs"`
+	Outputs     []string           `json:"outputs"`
+	DependsOn   []string           `json:"dependsOn"`
+}
+
+// InputDescription documents an input of an ingredient, taken from its JSON Schema.
+type InputDescription struct {
+	Name        string      `json:"name"`
+	Type        string      `json:"type"`
+	Required    bool        `json:"required"`
+	Default     interface{} `json:"default,omitempty"`
+	Description string      `json:"description,omitempty"`
+}
+
+// Describes a registered recipe by creating an instance of it with the given parameters, which may be nil.
+func DescribeRecipe(name string, params map[string]interface{}) (RecipeDescription, error) {
+	registered, ok := GetRegisteredRecipe(name)
+	if !ok {
+		return RecipeDescription{}, fmt.Errorf("recipe %q is not registered", name)
+	}
+
+	recipe, err := GetRecipeFromRegistry(name, name, params)
+	if err != nil {
+		return RecipeDescription{}, fmt.Errorf("recipe %q cannot be created to describe it: %w", name, err)
+	}
+
+	description := RecipeDescription{
+		Name:        registered.Name,
+		Description: registered.Description,
+		Ingredients: []IngredientDescription{},
+	}
+
+	for _, recipe := range flattenRecipes([]Recipe{recipe}) {
+		for i, ingredient := range recipe.Ingredients() {
+			ingredientDescription, err := describeIngredientFor(recipe, i, ingredient)
+			if err != nil {
+				return RecipeDescription{}, err
+			}
+
+			description.Ingredients = append(description.Ingredients, ingredientDescription)
+		}
+	}
+
+	return description, nil
+}
+
+func describeIngredientFor(recipe Recipe, index int, ingredient Ingredient) (IngredientDescription, error) {
+	description := IngredientDescription{
+		Recipe:    recipe.Name(),
+		Name:      strings.TrimPrefix(ingredientPath(recipe, index, ingredient), recipe.Name()+"/"),
+		Type:      ingredientType(ingredient),
+		Inputs:    []InputDescription{},
+		Outputs:   []string{},
+		DependsOn: []string{},
+	}
+
+	if declared, ok := ingredient.(*declaredIngredient); ok {
+		description.Description = declared.registered.Description
+	}
+
+	if documented, ok := ingredientAs[DocumentedIngredient](ingredient); ok {
+		if description.Description == "" {
+			description.Description = documented.Description()
+		}
+
+		description.Outputs = append(description.Outputs, documented.OutputNames()...)
+		sort.Strings(description.Outputs)
+	}
+
+	if dependent, ok := ingredientAs[DependentIngredient](ingredient); ok {
+		description.DependsOn = append(description.DependsOn, dependent.DependsOn()...)
+	}
+
+	if schemaIngredient, ok := ingredientAs[SchemaIngredient](ingredient); ok && schemaIngredient.InputSchema() != "" {
+		compiled, err := compileInputSchema(ingredientPath(recipe, index, ingredient), schemaIngredient.InputSchema())
+		if err != nil {
+			return IngredientDescription{}, fmt.Errorf("%s: invalid input schema: %w", ingredientPath(recipe, index, ingredient), err)
+		}
+
+		description.Inputs = describeInputs(compiled)
+	}
+
+	return description, nil
+}
+
+// Returns the type of an ingredient: the registered type name for declarative ingredients, the Go type otherwise.
+func ingredientType(ingredient Ingredient) string {
+	if declared, ok := ingredient.(*declaredIngredient); ok {
+		return declared.registered.Type
+	}
+
+	for {
+		wrapper, ok := ingredient.(interface{ Unwrap() Ingredient })
+		if !ok || wrapper.Unwrap() == nil {
+			break
+		}
+
+		ingredient = wrapper.Unwrap()
+	}
+
+	return reflect.TypeOf(ingredient).String()
+}
+
+// Returns the top-level properties of an object schema, sorted by name.
+func describeInputs(schema *jsonschema.Schema) []InputDescription {
+	schema = resolveSchema(schema)
+
+	required := map[string]bool{}
+	for _, name := range schema.Required {
+		required[name] = true
+	}
+
+	inputs := make([]InputDescription, 0, len(schema.Properties))
+
+	for name, property := range schema.Properties {
+		property = resolveSchema(property)
+
+		inputType := strings.Join(property.Types, "|")
+		if len(property.Enum) > 0 {
+			values := make([]string, 0, len(property.Enum))
+			for _, value := range property.Enum {
+				encoded, _ := json.Marshal(value)
+				values = append(values, string(encoded))
+			}
+
+			inputType = strings.Join(values, "|")
+		}
+
+		if inputType == "" {
+			inputType = "any"
+		}
+
+		inputs = append(inputs, InputDescription{
+			Name:        name,
+			Type:        inputType,
+			Required:    required[name],
+			Default:     property.Default,
+			Description: property.Description,
+		})
+	}
+
+	sort.Slice(inputs, func(i, j int) bool { return inputs[i].Name < inputs[j].Name })
+
+	return inputs
+}
+
+// Follows the $ref of a schema.
+func resolveSchema(schema *jsonschema.Schema) *jsonschema.Schema {
+	for schema.Ref != nil {
+		schema = schema.Ref
+	}
+
+	return schema
+}
+
+// Returns the description as JSON.
+func (d RecipeDescription) JSON() (string, error) {
+	encoded, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(encoded) + "\n", nil
+}
+
+// Returns the description as Markdown.
+func (d RecipeDescription) Markdown() string {
+	md := &strings.Builder{}
+
+	fmt.Fprintf(md, "# %s\n\n", d.Name)
+
+	if d.Description != "" {
+		fmt.Fprintf(md, "%s\n\n", d.Description)
+	}
+
+	fmt.Fprintf(md, "## Ingredients\n")
+
+	for _, ingredient := range d.Ingredients {
+		fmt.Fprintf(md, "\n### %s\n\n", ingredient.Name)
+
+		if ingredient.Recipe != d.Name {
+			fmt.Fprintf(md, "- Recipe: `%s`\n", ingredient.Recipe)
+		}
+
+		fmt.Fprintf(md, "- Type: `%s`\n", ingredient.Type)
+
+		if len(ingredient.DependsOn) > 0 {
+			fmt.Fprintf(md, "- Depends on: %s\n", markdownCodeList(ingredient.DependsOn))
+		}
+
+		if len(ingredient.Outputs) > 0 {
+			fmt.Fprintf(md, "- Outputs: %s\n", markdownCodeList(ingredient.Outputs))
+		}
+
+		if ingredient.Description != "" {
+			fmt.Fprintf(md, "\n%s\n", ingredient.Description)
+		}
+
+		if len(ingredient.Inputs) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(md, "\n| Input | Type | Required | Default | Description |\n")
+		fmt.Fprintf(md, "|-------|------|----------|---------|-------------|\n")
+
+		for _, input := range ingredient.Inputs {
+			required, defaultValue := "no", ""
+			if input.Required {
+				required = "yes"
+			}
+
+			if input.Default != nil {
+				encoded, _ := json.Marshal(input.Default)
+				defaultValue = "`" + string(encoded) + "`"
+			}
+
+			fmt.Fprintf(md, "| `%s` | %s | %s | %s | %s |\n", input.Name, markdownEscape(input.Type), required, defaultValue,
+				markdownEscape(input.Description))
+		}
+	}
+
+	return md.String()
+}
+
+func markdownCodeList(items []string) string {
+	quoted := make([]string, 0, len(items))
+	for _, item := range items {
+		quoted = append(quoted, "`"+item+"`")
+	}
+
+	return strings.Join(quoted, ", ")
+}
+
+// Escapes the characters breaking Markdown tables.
+func markdownEscape(text string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(text)
+}
@@ -0,0 +1,250 @@
+package common
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// The input schema of the "test-database" ingredient, with its properties defined by reference.
+const testDatabaseSchema = `{
+  "$ref": "#/$defs/database",
+  "$defs": {
+    "database": {
+      "type": "object",
+      "required": ["size"],
+      "properties": {
+        "size": {"$ref": "#/$defs/size"},
+        "engine": {"enum": ["postgres", "mysql"], "default": "postgres", "description": "The engine | flavor."},
+        "options": {}
+      }
+    },
+    "size": {"$ref": "#/$defs/gibibytes"},
+    "gibibytes": {"type": "integer", "default": 20, "description": "The storage in GiB."}
+  }
+}`
+
+// cdnIngredient documents itself.
+type cdnIngredient struct{}
+
+func (cdnIngredient) Upsert(*pulumi.Context) error { return nil }
+func (cdnIngredient) Result() interface{}          { return nil }
+func (cdnIngredient) Name() string                 { return "cdn" }
+func (cdnIngredient) Description() string          { return "Serves the assets." }
+func (cdnIngredient) OutputNames() []string        { return []string{"url", "domain"} }
+func (cdnIngredient) DependsOn() []string          { return []string{"shop-db-primary"} }
+
+// nolint: gochecknoinits
+func init() {
+	RegisterIngredientWithSchema("test-database", "A database.", testDatabaseSchema,
+		func(name string, _ map[string]interface{}, _ map[string]IngredientDependency) (Ingredient, error) {
+			return &outputIngredient{name: name}, nil
+		})
+
+	RegisterRecipe("test-described", "A shop with a database.", func(name string, _ map[string]interface{}) (Recipe, error) {
+		cfgs := []IngredientConfig{{Type: "test-database", Name: "primary", Properties: map[string]interface{}{"size": 10}}}
+
+		ingredients, errs := getDeclaredIngredients(cfgs, configPosition{}, "ingredients", NestedRecipeName(name, "db")+"-", AppEnvDevelopment)
+		if err := errs.OrNil(); err != nil {
+			return nil, err
+		}
+
+		db := GetDefaultRecipe(NestedRecipeName(name, "db"))
+		db.Append(ingredients...)
+
+		recipe := nestingRecipe(name, db)
+		recipe.Append(cdnIngredient{}, resultIngredient{})
+
+		return recipe, nil
+	})
+}
+
+func describeTestRecipe(t *testing.T) RecipeDescription {
+	t.Helper()
+
+	description, err := DescribeRecipe("test-described", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return description
+}
+
+func TestDescribeRecipe(t *testing.T) {
+	want := RecipeDescription{
+		Name:        "test-described",
+		Description: "A shop with a database.",
+		Ingredients: []IngredientDescription{
+			{
+				Recipe:      "test-described-db",
+				Name:        "test-described-db-primary",
+				Type:        "test-database",
+				Description: "A database.",
+				Inputs: []InputDescription{
+					{Name: "engine", Type: `"postgres"|"mysql"`, Default: "postgres", Description: "The engine | flavor."},
+					{Name: "options", Type: "any"},
+					{Name: "size", Type: "integer", Required: true, Default: json.Number("20"), Description: "The storage in GiB."},
+				},
+				Outputs:   []string{},
+				DependsOn: []string{},
+			},
+			{
+				Recipe:      "test-described",
+				Name:        "cdn",
+				Type:        "common.cdnIngredient",
+				Description: "Serves the assets.",
+				Inputs:      []InputDescription{},
+				Outputs:     []string{"domain", "url"},
+				DependsOn:   []string{"shop-db-primary"},
+			},
+			{
+				Recipe:    "test-described",
+				Name:      "#1",
+				Type:      "common.resultIngredient",
+				Inputs:    []InputDescription{},
+				Outputs:   []string{},
+				DependsOn: []string{},
+			},
+		},
+	}
+
+	if got := describeTestRecipe(t); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected:\n%+v\ngot:\n%+v", want, got)
+	}
+}
+
+func TestDescribeRecipeErrors(t *testing.T) {
+	if _, err := DescribeRecipe("no-such-recipe", nil); err == nil || err.Error() != `recipe "no-such-recipe" is not registered` {
+		t.Errorf("expected the recipe not to be registered, got %v", err)
+	}
+}
+
+func TestRecipeDescriptionJSON(t *testing.T) {
+	want := `{
+  "name": "test-described",
+  "description": "A shop with a database.",
+  "ingredients": [
+    {
+      "recipe": "test-described-db",
+      "name": "test-described-db-primary",
+      "type": "test-database",
+      "description": "A database.",
+      "inputs": [
+        {
+          "name": "engine",
+          "type": "\"postgres\"|\"mysql\"",
+          "required": false,
+          "default": "postgres",
+          "description": "The engine | flavor."
+        },
+        {
+          "name": "options",
+          "type": "any",
+          "required": false
+        },
+        {
+          "name": "size",
+          "type": "integer",
+          "required": true,
+          "default": 20,
+          "description": "The storage in GiB."
+        }
+      ],
+      "outputs": [],
+      "dependsOn": []
+    },
+    {
+      "recipe": "test-described",
+      "name": "cdn",
+      "type": "common.cdnIngredient",
+      "description": "Serves the assets.",
+      "inputs": [],
+      "outputs": [
+        "domain",
+        "url"
+      ],
+      "dependsOn": [
+        "shop-db-primary"
+      ]
+    },
+    {
+      "recipe": "test-described",
+      "name": "#1",
+      "type": "common.resultIngredient",
+      "inputs": [],
+      "outputs": [],
+      "dependsOn": []
+    }
+  ]
+}
+`
+
+	got, err := describeTestRecipe(t).JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestRecipeDescriptionMarkdown(t *testing.T) {
+	want := "# test-described\n" +
+		"\n" +
+		"A shop with a database.\n" +
+		"\n" +
+		"## Ingredients\n" +
+		"\n" +
+		"### test-described-db-primary\n" +
+		"\n" +
+		"- Recipe: `test-described-db`\n" +
+		"- Type: `test-database`\n" +
+		"\n" +
+		"A database.\n" +
+		"\n" +
+		"| Input | Type | Required | Default | Description |\n" +
+		"|-------|------|----------|---------|-------------|\n" +
+		"| `engine` | \"postgres\"\\|\"mysql\" | no | `\"postgres\"` | The engine \\| flavor. |\n" +
+		"| `options` | any | no |  |  |\n" +
+		"| `size` | integer | yes | `20` | The storage in GiB. |\n" +
+		"\n" +
+		"### cdn\n" +
+		"\n" +
+		"- Type: `common.cdnIngredient`\n" +
+		"- Depends on: `shop-db-primary`\n" +
+		"- Outputs: `domain`, `url`\n" +
+		"\n" +
+		"Serves the assets.\n" +
+		"\n" +
+		"### #1\n" +
+		"\n" +
+		"- Type: `common.resultIngredient`\n"
+
+	if got := describeTestRecipe(t).Markdown(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestResolveSchema(t *testing.T) {
+	compiled, err := compileInputSchema("test-database", testDatabaseSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	database := resolveSchema(compiled)
+	if !reflect.DeepEqual(database.Types, []string{"object"}) {
+		t.Fatalf("expected the referenced object schema, got the types %v", database.Types)
+	}
+
+	size := resolveSchema(database.Properties["size"])
+	if !reflect.DeepEqual(size.Types, []string{"integer"}) || size.Description != "The storage in GiB." {
+		t.Errorf("expected the schema referenced twice, got the types %v and description %q", size.Types, size.Description)
+	}
+
+	if engine := database.Properties["engine"]; resolveSchema(engine) != engine {
+		t.Error("expected a schema without reference to resolve to itself")
+	}
+}
@@ -0,0 +1,291 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RecipeFactory creates a named recipe from the parameters given in rapid.yaml.
+type RecipeFactory func(name string, params map[string]interface{}) (Recipe, error)
+
+type RegisteredRecipe struct {
+	Name        string        // The name the recipe is registered under.
+	Description string        // A one-line description, shown by "recipes list".
+	Factory     RecipeFactory // Creates instances of the recipe.
+}
+
+// RecipeConfig is an entry of the "recipes" section of rapid.yaml.
+type RecipeConfig struct {
+	// The name of the recipe in the stack, defaults to the registered recipe name.
+	Name string `mapstructure:"name" yaml:"name"`
+	// The name of the registered recipe, may be empty for purely declarative recipes.
+	Recipe string `mapstructure:"recipe" yaml:"recipe,omitempty"`
+	// The parameters passed to the recipe factory.
+	Params map[string]interface{} `mapstructure:"params" yaml:"params,omitempty"`
+	// The IDs or names of the environments the recipe exists in, e.g. [prd], all environments if empty.
+	Environments []string `mapstructure:"environments" yaml:"environments,omitempty"`
+	// Parameters by environment ID or name, merged recursively over the parameters above.
+	Overrides map[string]map[string]interface{} `mapstructure:"overrides" yaml:"overrides,omitempty"`
+	// Declarative ingredients, appended to the registered recipe.
+	Ingredients []IngredientConfig `mapstructure:"ingredients" yaml:"ingredients,omitempty"`
+	// Included recipes, named with the name of this recipe as prefix.
+	Include []RecipeConfig `mapstructure:"include" yaml:"include,omitempty"`
+
+	position configPosition // Where the recipe is defined in rapid.yaml.
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler, keeping the position of the recipe for error messages.
+func (rc *RecipeConfig) UnmarshalYAML(node *yaml.Node) error {
+	type plain RecipeConfig
+
+	if err := node.Decode((*plain)(rc)); err != nil {
+		return err
+	}
+
+	rc.position = configPosition{node: node}
+
+	return nil
+}
+
+// Returns true if the recipe exists in an application environment.
+func (rc RecipeConfig) EnabledIn(env ApplicationEnvironment) bool {
+	return environmentsContain(rc.Environments, env)
+}
+
+// Returns the recipe as configured for an application environment, with the overrides of the environment merged
+// into the parameters, and without any environment specific settings. Ingredients and included recipes are kept.
+func (rc RecipeConfig) ForEnvironment(env ApplicationEnvironment) RecipeConfig {
+	if key, ok := overridesKey(rc.Overrides, env); ok {
+		rc.Params = mergeProperties(rc.Params, rc.Overrides[key])
+	}
+
+	rc.Environments = nil
+	rc.Overrides = nil
+
+	return rc
+}
+
+// nolint: gochecknoglobals
+var (
+	recipeRegistryMu sync.RWMutex
+	recipeRegistry   = map[string]RegisteredRecipe{}
+)
+
+// Registers a recipe factory under a name, usually from the init function of the package providing the recipe.
+// Registering the same name twice panics.
+func RegisterRecipe(name, description string, factory RecipeFactory) {
+	recipeRegistryMu.Lock()
+	defer recipeRegistryMu.Unlock()
+
+	if factory == nil {
+		panic("RegisterRecipe: factory for " + name + " is nil")
+	}
+
+	if _, exists := recipeRegistry[name]; exists {
+		panic("RegisterRecipe: recipe " + name + " registered twice")
+	}
+
+	recipeRegistry[name] = RegisteredRecipe{
+		Name:        name,
+		Description: description,
+		Factory:     factory,
+	}
+}
+
+// Returns all registered recipes, sorted by name.
+func RegisteredRecipes() []RegisteredRecipe {
+	recipeRegistryMu.RLock()
+	defer recipeRegistryMu.RUnlock()
+
+	recipes := make([]RegisteredRecipe, 0, len(recipeRegistry))
+	for _, recipe := range recipeRegistry {
+		recipes = append(recipes, recipe)
+	}
+
+	sort.Slice(recipes, func(i, j int) bool { return recipes[i].Name < recipes[j].Name })
+
+	return recipes
+}
+
+// Returns the recipe registered under a name.
+func GetRegisteredRecipe(name string) (RegisteredRecipe, bool) {
+	recipeRegistryMu.RLock()
+	defer recipeRegistryMu.RUnlock()
+
+	recipe, ok := recipeRegistry[name]
+
+	return recipe, ok
+}
+
+// Creates an instance of the recipe registered under a name, with the given instance name and parameters.
+func GetRecipeFromRegistry(recipe, name string, params map[string]interface{}) (Recipe, error) {
+	registered, ok := GetRegisteredRecipe(recipe)
+	if !ok {
+		return nil, fmt.Errorf("recipe %q is not registered", recipe)
+	}
+
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+
+	return registered.Factory(name, params)
+}
+
+// Creates the recipes listed in the configuration from the registered recipe and ingredient factories.
+// Ingredients are created as configured for the application environment.
+// All problems found are returned at once as ConfigErrors.
+func GetRecipes(cfgs []RecipeConfig, env ApplicationEnvironment) ([]Recipe, error) {
+	recipes, errs := getConfigRecipes(cfgs, configPosition{}, "recipes", "", env)
+
+	if err := errs.OrNil(); err != nil {
+		return nil, err
+	}
+
+	return recipes, nil
+}
+
+// Creates the recipes listed in the configuration like GetRecipes, and validates the ones that could be created
+// like ValidateRecipes. The problems of both are returned at once as ConfigErrors.
+func ValidateRecipeConfigs(cfgs []RecipeConfig, env ApplicationEnvironment) error {
+	recipes, errs := getConfigRecipes(cfgs, configPosition{}, "recipes", "", env)
+
+	if err := ValidateRecipes(recipes); err != nil {
+		problems := ConfigErrors{}
+		if !errors.As(err, &problems) {
+			return err
+		}
+
+		errs = append(errs, problems...)
+	}
+
+	return errs.OrNil()
+}
+
+// Creates a list of recipes, the ones included by a parent if parent is not empty.
+// The position is the one of the list, unless the list is the top-level recipes section.
+func getConfigRecipes(cfgs []RecipeConfig, listPos configPosition, listPath, parent string, env ApplicationEnvironment) ([]Recipe, ConfigErrors) {
+	recipes := make([]Recipe, 0, len(cfgs))
+	names := map[string]bool{}
+	errs := ConfigErrors{}
+
+	for i, cfg := range cfgs {
+		path := fmt.Sprintf("%s[%d]", listPath, i)
+
+		pos := cfg.position
+		if listPos.node != nil {
+			pos = listPos.item(i)
+		}
+
+		errs = append(errs, pos.unknownKeys(path, "name", "recipe", "params", "environments", "overrides", "ingredients", "include")...)
+		errs = append(errs, environmentProblems(cfg.Environments, cfg.Overrides, pos, path)...)
+
+		name := cfg.Name
+		if name == "" {
+			name = cfg.Recipe
+		}
+
+		if name == "" {
+			errs = append(errs, pos.errorf(path, nil, "either name or recipe must be set"))
+
+			continue
+		}
+
+		if names[name] {
+			errs = append(errs, pos.errorf(path+".name", []string{"name"}, "recipe name %q is used twice", name))
+
+			continue
+		}
+
+		names[name] = true
+
+		if !cfg.EnabledIn(env) {
+			continue
+		}
+
+		cfg = cfg.ForEnvironment(env)
+
+		prefix := ""
+		if parent != "" {
+			name = NestedRecipeName(parent, name)
+			prefix = name + "-"
+		}
+
+		recipe := GetDefaultRecipe(name)
+
+		if cfg.Recipe != "" {
+			if _, ok := GetRegisteredRecipe(cfg.Recipe); !ok {
+				errs = append(errs, pos.errorf(path+".recipe", []string{"recipe"}, "recipe %q is not registered", cfg.Recipe))
+
+				continue
+			}
+
+			created, err := GetRecipeFromRegistry(cfg.Recipe, name, cfg.Params)
+			if err != nil {
+				errs = append(errs, pos.errorf(path+".params", []string{"params"}, "%w", err))
+
+				continue
+			}
+
+			recipe = created
+		} else if len(cfg.Ingredients) == 0 && len(cfg.Include) == 0 {
+			errs = append(errs, pos.errorf(path, nil, "either recipe, ingredients or include must be set"))
+
+			continue
+		}
+
+		ingredients, ingredientErrs := getDeclaredIngredients(cfg.Ingredients, pos.child("ingredients"), path+".ingredients", prefix, env)
+		errs = append(errs, ingredientErrs...)
+
+		recipe.Append(ingredients...)
+
+		if len(cfg.Include) > 0 {
+			included, includeErrs := getConfigRecipes(cfg.Include, pos.child("include"), path+".include", name, env)
+			errs = append(errs, includeErrs...)
+
+			nesting, ok := recipe.(NestingRecipe)
+			if !ok {
+				errs = append(errs, pos.errorf(path+".include", []string{"include"}, "recipe %q cannot include other recipes", cfg.Recipe))
+
+				continue
+			}
+
+			nesting.Include(included...)
+		}
+
+		recipes = append(recipes, recipe)
+	}
+
+	return recipes, errs
+}
+
+// Returns the recipe configurations as they apply to an application environment: recipes and ingredients not
+// enabled in it are removed, and the overrides of the environment are merged into the parameters and properties
+// of the others.
+func ResolveRecipeConfigs(cfgs []RecipeConfig, env ApplicationEnvironment) []RecipeConfig {
+	resolved := make([]RecipeConfig, 0, len(cfgs))
+
+	for _, cfg := range cfgs {
+		if !cfg.EnabledIn(env) {
+			continue
+		}
+
+		cfg = cfg.ForEnvironment(env)
+		ingredients := make([]IngredientConfig, 0, len(cfg.Ingredients))
+
+		for _, ingredient := range cfg.Ingredients {
+			if ingredient.EnabledIn(env) {
+				ingredients = append(ingredients, ingredient.ForEnvironment(env))
+			}
+		}
+
+		cfg.Ingredients = ingredients
+		cfg.Include = ResolveRecipeConfigs(cfg.Include, env)
+		resolved = append(resolved, cfg)
+	}
+
+	return resolved
+}
@@ -0,0 +1,114 @@
+package common
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+type invalidIngredient struct{}
+
+func (invalidIngredient) Upsert(*pulumi.Context) error { return nil }
+func (invalidIngredient) Result() interface{}          { return nil }
+func (invalidIngredient) Validate() error              { return errors.New("size is too small") }
+
+// nolint: gochecknoinits
+func init() {
+	RegisterRecipe("test-invalid", "A recipe whose ingredient fails validation", func(name string, _ map[string]interface{}) (Recipe, error) {
+		recipe := GetDefaultRecipe(name)
+		recipe.Append(invalidIngredient{})
+
+		return recipe, nil
+	})
+}
+
+func TestValidateRecipeConfigsReportsAllProblems(t *testing.T) {
+	cfgs := []RecipeConfig{
+		{Name: "broken", Ingredients: []IngredientConfig{{Type: "no-such-type", Name: "thing"}}},
+		{Name: "invalid", Recipe: "test-invalid"},
+		{Name: "invalid", Recipe: "test-invalid"},
+	}
+
+	err := ValidateRecipeConfigs(cfgs, AppEnvDevelopment)
+
+	problems := ConfigErrors{}
+	if !errors.As(err, &problems) {
+		t.Fatalf("expected ConfigErrors, got %v", err)
+	}
+
+	want := []string{
+		`recipes[0].ingredients[0].type: ingredient type "no-such-type" is not registered`,
+		`recipes[2].name: recipe name "invalid" is used twice`,
+		`size is too small`,
+	}
+
+	if len(problems) != len(want) {
+		t.Fatalf("expected %d problems, got %d:\n%v", len(want), len(problems), err)
+	}
+
+	for i, problem := range problems {
+		if !strings.Contains(problem.Error(), want[i]) {
+			t.Errorf("problem %d: %q does not contain %q", i, problem.Error(), want[i])
+		}
+	}
+}
+
+func TestRegisterRecipe(t *testing.T) {
+	registered, ok := GetRegisteredRecipe("test-invalid")
+	if !ok {
+		t.Fatal("test-invalid is not registered")
+	}
+
+	recipe, err := registered.Factory("invalid", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if recipe.Name() != "invalid" || len(recipe.Ingredients()) != 1 {
+		t.Errorf("expected the recipe invalid with one ingredient, got %s with %d", recipe.Name(), len(recipe.Ingredients()))
+	}
+
+	listed := false
+
+	for i, r := range RegisteredRecipes() {
+		if i > 0 && RegisteredRecipes()[i-1].Name > r.Name {
+			t.Errorf("registered recipes are not sorted by name: %s before %s", RegisteredRecipes()[i-1].Name, r.Name)
+		}
+
+		listed = listed || r.Name == "test-invalid"
+	}
+
+	if !listed {
+		t.Error("test-invalid is not listed")
+	}
+
+	if _, ok := GetRegisteredRecipe("no-such-recipe"); ok {
+		t.Error("expected no-such-recipe not to be registered")
+	}
+}
+
+func TestRegisterRecipePanics(t *testing.T) {
+	factory := func(name string, _ map[string]interface{}) (Recipe, error) { return GetDefaultRecipe(name), nil }
+
+	tests := []struct {
+		name     string
+		register func()
+	}{
+		{name: "twice", register: func() { RegisterRecipe("test-invalid", "", factory) }},
+		{name: "without factory", register: func() { RegisterRecipe("test-nil", "", nil) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected a panic")
+				}
+			}()
+
+			tt.register()
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// outputIngredient exports fixed outputs.
+type outputIngredient struct {
+	name    string
+	outputs pulumi.Map
+}
+
+func (oi *outputIngredient) Upsert(*pulumi.Context) error { return nil }
+func (oi *outputIngredient) Result() interface{}          { return nil }
+func (oi *outputIngredient) Name() string                 { return oi.name }
+func (oi *outputIngredient) Outputs() pulumi.Map          { return oi.outputs }
+
+// flatRecipe is a recipe that cannot include other recipes.
+type flatRecipe struct {
+	Recipe
+}
+
+// nolint: gochecknoinits
+func init() {
+	RegisterIngredient("test-output", "An ingredient exporting its properties as string outputs",
+		func(name string, properties map[string]interface{}, _ map[string]IngredientDependency) (Ingredient, error) {
+			outputs := pulumi.Map{}
+			for key, value := range properties {
+				outputs[key] = pulumi.String(fmt.Sprint(value))
+			}
+
+			return &outputIngredient{name: name, outputs: outputs}, nil
+		})
+
+	RegisterRecipe("test-flat", "A recipe that cannot include other recipes", func(name string, _ map[string]interface{}) (Recipe, error) {
+		return flatRecipe{Recipe: GetDefaultRecipe(name)}, nil
+	})
+}
+
+// Returns the names of the recipes.
+func recipeNames(recipes []Recipe) []string {
+	names := []string{}
+	for _, recipe := range recipes {
+		names = append(names, recipe.Name())
+	}
+
+	return names
+}
+
+func TestFlattenRecipes(t *testing.T) {
+	recipes := []Recipe{
+		nestingRecipe("shop", nestingRecipe("shop-web", GetDefaultRecipe("shop-web-db")), GetDefaultRecipe("shop-queue")),
+		GetDefaultRecipe("cdn"),
+	}
+
+	want := []string{"shop-web-db", "shop-web", "shop-queue", "shop", "cdn"}
+	if got := recipeNames(flattenRecipes(recipes)); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestRecipeOutputs(t *testing.T) {
+	output := func(name string, outputs pulumi.Map) Ingredient {
+		return &outputIngredient{name: name, outputs: outputs}
+	}
+
+	tests := []struct {
+		name    string
+		recipe  func() Recipe
+		want    pulumi.Map
+		wantErr string
+	}{
+		{
+			name: "namespaced by included recipe",
+			recipe: func() Recipe {
+				db := GetDefaultRecipe("shop-db")
+				db.Append(output("primary", pulumi.Map{"endpoint": pulumi.String("db:5432")}))
+
+				shop := nestingRecipe("shop", db)
+				shop.Append(output("web", pulumi.Map{"url": pulumi.String("https://shop")}), invalidIngredient{})
+
+				return shop
+			},
+			want: pulumi.Map{
+				"url": pulumi.String("https://shop"),
+				"db":  pulumi.Map{"endpoint": pulumi.String("db:5432")},
+			},
+		},
+		{
+			name: "exported twice",
+			recipe: func() Recipe {
+				shop := GetDefaultRecipe("shop")
+				shop.Append(output("web", pulumi.Map{"url": pulumi.String("a")}), output("api", pulumi.Map{"url": pulumi.String("b")}))
+
+				return shop
+			},
+			wantErr: `recipe shop: output "url" is exported twice`,
+		},
+		{
+			name: "clashing with an included recipe",
+			recipe: func() Recipe {
+				shop := nestingRecipe("shop", GetDefaultRecipe("shop-db"))
+				shop.Append(output("web", pulumi.Map{"db": pulumi.String("a")}))
+
+				return shop
+			},
+			wantErr: `recipe shop: output "db" of the included recipe clashes with another output`,
+		},
+		{
+			name: "twice in an included recipe",
+			recipe: func() Recipe {
+				db := GetDefaultRecipe("shop-db")
+				db.Append(output("a", pulumi.Map{"host": pulumi.String("a")}), output("b", pulumi.Map{"host": pulumi.String("b")}))
+
+				return nestingRecipe("shop", db)
+			},
+			wantErr: `recipe shop-db: output "host" is exported twice`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := recipeOutputs(tt.recipe())
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("expected the error %q, got %v", tt.wantErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGetRecipesIncludes(t *testing.T) {
+	cfgs := []RecipeConfig{{
+		Name:        "shop",
+		Ingredients: []IngredientConfig{{Type: "test-output", Name: "web", Properties: map[string]interface{}{"url": "https://shop"}}},
+		Include: []RecipeConfig{{
+			Name:        "db",
+			Ingredients: []IngredientConfig{{Type: "test-output", Name: "primary", Properties: map[string]interface{}{"port": 5432}}},
+			Include:     []RecipeConfig{{Name: "backup", Recipe: "test-sized"}},
+		}},
+	}}
+
+	recipes, err := GetRecipes(cfgs, AppEnvDevelopment)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantRecipes := []string{"shop-db-backup", "shop-db", "shop"}
+	if got := recipeNames(flattenRecipes(recipes)); !reflect.DeepEqual(got, wantRecipes) {
+		t.Errorf("expected the recipes %v, got %v", wantRecipes, got)
+	}
+
+	ingredients := []string{}
+	for _, recipe := range flattenRecipes(recipes) {
+		for i, ingredient := range recipe.Ingredients() {
+			ingredients = append(ingredients, ingredientPath(recipe, i, ingredient))
+		}
+	}
+
+	wantIngredients := []string{"shop-db-backup/#0", "shop-db/shop-db-primary", "shop/web"}
+	if !reflect.DeepEqual(ingredients, wantIngredients) {
+		t.Errorf("expected the ingredients %v, got %v", wantIngredients, ingredients)
+	}
+
+	outputs, err := recipeOutputs(recipes[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantOutputs := pulumi.Map{
+		"url": pulumi.String("https://shop"),
+		"db":  pulumi.Map{"port": pulumi.String("5432"), "backup": pulumi.Map{}},
+	}
+
+	if !reflect.DeepEqual(outputs, wantOutputs) {
+		t.Errorf("expected the outputs %v, got %v", wantOutputs, outputs)
+	}
+}
+
+func TestGetRecipesIncludeProblems(t *testing.T) {
+	cfgs := []RecipeConfig{
+		{
+			Name:        "shop",
+			Ingredients: []IngredientConfig{{Type: "test-output", Name: "web"}},
+			Include:     []RecipeConfig{{Name: "db", Recipe: "test-sized"}, {Recipe: "test-sized", Name: "db"}},
+		},
+		{Name: "shop-db", Recipe: "test-sized"},
+		{Name: "cdn", Recipe: "test-flat", Include: []RecipeConfig{{Name: "edge", Recipe: "test-sized"}}},
+	}
+
+	err := ValidateRecipeConfigs(cfgs, AppEnvDevelopment)
+
+	problems := ConfigErrors{}
+	if !errors.As(err, &problems) {
+		t.Fatalf("expected ConfigErrors, got %v", err)
+	}
+
+	want := []string{
+		`recipes[0].include[1].name: recipe name "db" is used twice`,
+		`recipes[2].include: recipe "test-flat" cannot include other recipes`,
+		`shop-db: recipe name is used twice`,
+	}
+
+	if len(problems) != len(want) {
+		t.Fatalf("expected %d problems, got %d:\n%v", len(want), len(problems), err)
+	}
+
+	for i, problem := range problems {
+		if !strings.Contains(problem.Error(), want[i]) {
+			t.Errorf("problem %d: %q does not contain %q", i, problem.Error(), want[i])
+		}
+	}
+}
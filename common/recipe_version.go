@@ -0,0 +1,276 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/apex/log"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// The prefix of the stack tags recording the deployed versions of the recipes.
+const recipeVersionTagPrefix = "cloudprism:recipe"
+
+// The rule stack tag names must follow.
+// nolint: gochecknoglobals
+var stackTagNamingRule = NamingRule{MaxLength: 40, Charset: "a-zA-Z0-9_.:-", Separator: "-"}
+
+// VersionedRecipe is implemented by recipes whose resource structure is versioned. The chef records the deployed
+// version of every versioned recipe in the update message, and refuses to upgrade a stack to a newer version unless
+// migrations from the deployed version to the new one are registered.
+type VersionedRecipe interface {
+	Recipe
+
+	// Returns the kind of the recipe, usually the name it is registered under, e.g. "webservice".
+	Kind() string
+
+	// Returns the version of the resource structure, starting at 1.
+	Version() int
+}
+
+// RecipeMigration upgrades the resources of a kind of recipe from one version to the next, without replacing them.
+type RecipeMigration struct {
+	Kind        string // The kind of recipe.
+	From        int    // The version migrated from, to From+1.
+	Description string // A one-line description, e.g. "moves the bucket into the storage component".
+
+	// Returns the aliases of a resource of the new version, by its type and logical name, pointing to the
+	// resource of the old version. Resources without aliases return nil.
+	Aliases func(resourceType, name string) []pulumi.Alias
+}
+
+// nolint: gochecknoglobals
+var (
+	recipeMigrationsMu sync.RWMutex
+	recipeMigrations   = map[string]map[int]RecipeMigration{}
+)
+
+// Registers a migration, usually from the init function of the package providing the recipe.
+// Registering two migrations of the same kind from the same version panics.
+func RegisterRecipeMigration(migration RecipeMigration) {
+	recipeMigrationsMu.Lock()
+	defer recipeMigrationsMu.Unlock()
+
+	if migration.Aliases == nil {
+		panic(fmt.Sprintf("RegisterRecipeMigration: aliases of %s from version %d are nil", migration.Kind, migration.From))
+	}
+
+	if recipeMigrations[migration.Kind] == nil {
+		recipeMigrations[migration.Kind] = map[int]RecipeMigration{}
+	}
+
+	if _, exists := recipeMigrations[migration.Kind][migration.From]; exists {
+		panic(fmt.Sprintf("RegisterRecipeMigration: migration of %s from version %d registered twice", migration.Kind, migration.From))
+	}
+
+	recipeMigrations[migration.Kind][migration.From] = migration
+}
+
+// Returns the migrations of a kind of recipe from one version to another, in order.
+// An error is returned if a migration is missing or the versions go backwards.
+func GetRecipeMigrations(kind string, from, to int) ([]RecipeMigration, error) {
+	recipeMigrationsMu.RLock()
+	defer recipeMigrationsMu.RUnlock()
+
+	if from > to {
+		return nil, fmt.Errorf("downgrading %s from version %d to %d is not supported", kind, from, to)
+	}
+
+	migrations := make([]RecipeMigration, 0, to-from)
+	missing := []string{}
+
+	for version := from; version < to; version++ {
+		migration, ok := recipeMigrations[kind][version]
+		if !ok {
+			missing = append(missing, fmt.Sprintf("%d to %d", version, version+1))
+
+			continue
+		}
+
+		migrations = append(migrations, migration)
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("upgrading %s from version %d to %d is unsafe, no migration from %s", kind, from, to, strings.Join(missing, ", "))
+	}
+
+	return migrations, nil
+}
+
+// Adds the aliases of the migrations to the resources of the recipes, by recipe name.
+func WithRecipeMigrations(migrations map[string][]RecipeMigration) ProgramOption {
+	return func(opts *programOptions) {
+		opts.migrations = migrations
+	}
+}
+
+// A stack transformation adding the aliases of the migrations of the recipe being upserted to a resource.
+// Resources created asynchronously, e.g. in ApplyT, get no aliases.
+func (ps *programState) aliasResource(args *pulumi.ResourceTransformationArgs) *pulumi.ResourceTransformationResult {
+	if ps.recipe == nil {
+		return nil
+	}
+
+	aliases := []pulumi.Alias{}
+
+	for _, migration := range ps.options.migrations[ps.recipe.Name()] {
+		aliases = append(aliases, migration.Aliases(args.Type, args.Name)...)
+	}
+
+	if len(aliases) == 0 {
+		return nil
+	}
+
+	log.WithFields(log.Fields{
+		"recipe":   ps.recipe.Name(),
+		"resource": args.Name,
+		"type":     args.Type,
+		"aliases":  len(aliases),
+	}).Debug("Aliasing resource")
+
+	return &pulumi.ResourceTransformationResult{
+		Props: args.Props,
+		Opts:  append(args.Opts, pulumi.Aliases(aliases)),
+	}
+}
+
+// Returns the name of the stack tag recording the deployed version of a recipe.
+func recipeVersionTag(recipe Recipe) string {
+	// The rule has neither a minimum length nor a required first character, so it cannot fail.
+	tag, _ := stackTagNamingRule.apply(recipeVersionTagPrefix, recipe.Name())
+
+	return tag
+}
+
+// Returns the value of the stack tag recording the version of a recipe, like "webservice@3".
+func recipeVersionTagValue(recipe VersionedRecipe) string {
+	return recipe.Kind() + "@" + strconv.Itoa(recipe.Version())
+}
+
+// Returns the migrations needed to upgrade the versioned recipes from the versions recorded in the deployment,
+// by recipe name. Recipes without a recorded version are deployed for the first time and need no migration.
+// Without a recorded deployment, the deployed versions are unknown, and recipes past version 1 are refused.
+func planRecipeMigrations(recipes []Recipe, deployed *deploymentRecord) (map[string][]RecipeMigration, error) {
+	plan := map[string][]RecipeMigration{}
+	problems := []string{}
+
+	for _, recipe := range flattenRecipes(recipes) {
+		versioned, ok := recipe.(VersionedRecipe)
+		if !ok {
+			continue
+		}
+
+		if deployed == nil {
+			if versioned.Version() > 1 {
+				problems = append(problems, fmt.Sprintf("recipe %s: the deployed version is not recorded, upgrading to version %d is unsafe",
+					recipe.Name(), versioned.Version()))
+			}
+
+			continue
+		}
+
+		recorded, ok := deployed.Recipes[recipe.Name()]
+		if !ok {
+			continue
+		}
+
+		kind, version, err := parseRecipeVersionTagValue(recorded)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("recipe %s: %v", recipe.Name(), err))
+
+			continue
+		}
+
+		if kind != versioned.Kind() {
+			problems = append(problems, fmt.Sprintf("recipe %s: deployed as %s, cannot be changed to %s", recipe.Name(), kind, versioned.Kind()))
+
+			continue
+		}
+
+		migrations, err := GetRecipeMigrations(kind, version, versioned.Version())
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("recipe %s: %v", recipe.Name(), err))
+
+			continue
+		}
+
+		if len(migrations) > 0 {
+			plan[recipe.Name()] = migrations
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+
+		return nil, fmt.Errorf("refusing to upgrade the stack:\n%s", strings.Join(problems, "\n"))
+	}
+
+	return plan, nil
+}
+
+func parseRecipeVersionTagValue(value string) (string, int, error) {
+	index := strings.LastIndex(value, "@")
+	if index < 0 {
+		return "", 0, fmt.Errorf("invalid recipe version %q", value)
+	}
+
+	version, err := strconv.Atoi(value[index+1:])
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid recipe version %q", value)
+	}
+
+	return value[:index], version, nil
+}
+
+// Plans the migrations of the versioned recipes from the versions recorded by the last update of the stack.
+func (dc *defaultChef) planMigrations(ctx context.Context, stack auto.Stack) error {
+	deployed, err := dc.readDeployment(ctx, stack)
+	if err != nil {
+		return err
+	}
+
+	plan, err := planRecipeMigrations(dc.recipes, deployed)
+	if err != nil {
+		return err
+	}
+
+	for name, migrations := range plan {
+		for _, migration := range migrations {
+			log.WithFields(dc.fields()).WithFields(log.Fields{
+				"recipe":      name,
+				"kind":        migration.Kind,
+				"fromVersion": migration.From,
+				"toVersion":   migration.From + 1,
+			}).Info("Migrating recipe: " + migration.Description)
+		}
+	}
+
+	dc.deployed = deployed
+	dc.migrations = plan
+
+	return nil
+}
+
+// Mirrors the versions of the versioned recipes into the tags of the stack, where the state store keeps them.
+// The update already succeeded, so failures are logged only.
+func (dc *defaultChef) recordRecipeVersions(ctx context.Context, stack auto.Stack) {
+	if !stackTagsSupported(stack) {
+		return
+	}
+
+	for _, recipe := range flattenRecipes(dc.recipes) {
+		versioned, ok := recipe.(VersionedRecipe)
+		if !ok {
+			continue
+		}
+
+		if err := stack.SetTag(ctx, recipeVersionTag(recipe), recipeVersionTagValue(versioned)); err != nil {
+			log.WithFields(dc.fields()).WithField("recipe", recipe.Name()).WithError(err).Warn("DefaultChef recording recipe version failed")
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package common
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// versionedRecipe is a recipe of the kind "test-versioned".
+type versionedRecipe struct {
+	Recipe
+	version int
+}
+
+func (versionedRecipe) Kind() string { return "test-versioned" }
+
+func (vr versionedRecipe) Version() int { return vr.version }
+
+// nolint: gochecknoinits
+func init() {
+	for from := 1; from <= 2; from++ {
+		RegisterRecipeMigration(RecipeMigration{
+			Kind:        "test-versioned",
+			From:        from,
+			Description: "renames the bucket",
+			Aliases:     func(string, string) []pulumi.Alias { return nil },
+		})
+	}
+}
+
+func migrationVersions(migrations []RecipeMigration) []int {
+	versions := []int{}
+	for _, migration := range migrations {
+		versions = append(versions, migration.From)
+	}
+
+	return versions
+}
+
+func TestGetRecipeMigrations(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    int
+		to      int
+		want    []int
+		wantErr string
+	}{
+		{name: "same version", from: 2, to: 2, want: []int{}},
+		{name: "one version", from: 2, to: 3, want: []int{2}},
+		{name: "two versions in order", from: 1, to: 3, want: []int{1, 2}},
+		{name: "missing migrations", from: 1, to: 5, wantErr: "no migration from 3 to 4, 4 to 5"},
+		{name: "downgrade", from: 3, to: 1, wantErr: "downgrading test-versioned from version 3 to 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := GetRecipeMigrations("test-versioned", tt.from, tt.to)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := migrationVersions(migrations); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected migrations from %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPlanRecipeMigrations(t *testing.T) {
+	web := versionedRecipe{Recipe: GetDefaultRecipe("web"), version: 3}
+	first := versionedRecipe{Recipe: GetDefaultRecipe("web"), version: 1}
+	plain := GetDefaultRecipe("plain")
+
+	tests := []struct {
+		name     string
+		recipes  []Recipe
+		deployed *deploymentRecord
+		want     map[string][]int
+		wantErr  string
+	}{
+		{name: "first deployment", recipes: []Recipe{web, plain}, deployed: &deploymentRecord{}, want: map[string][]int{}},
+		{
+			name:     "recipe added",
+			recipes:  []Recipe{web},
+			deployed: &deploymentRecord{Recipes: map[string]string{"api": "test-versioned@1"}},
+			want:     map[string][]int{},
+		},
+		{
+			name:     "deployed version",
+			recipes:  []Recipe{web},
+			deployed: &deploymentRecord{Recipes: map[string]string{"web": "test-versioned@3"}},
+			want:     map[string][]int{},
+		},
+		{
+			name:     "older version",
+			recipes:  []Recipe{web, plain},
+			deployed: &deploymentRecord{Recipes: map[string]string{"web": "test-versioned@1"}},
+			want:     map[string][]int{"web": {1, 2}},
+		},
+		{
+			name:     "nested recipe",
+			recipes:  []Recipe{nestingRecipe("shop", versionedRecipe{Recipe: GetDefaultRecipe("shop-web"), version: 3})},
+			deployed: &deploymentRecord{Recipes: map[string]string{"shop-web": "test-versioned@2"}},
+			want:     map[string][]int{"shop-web": {2}},
+		},
+		{
+			name:     "newer version",
+			recipes:  []Recipe{first},
+			deployed: &deploymentRecord{Recipes: map[string]string{"web": "test-versioned@2"}},
+			wantErr:  "recipe web: downgrading test-versioned from version 2 to 1",
+		},
+		{
+			name:     "other kind",
+			recipes:  []Recipe{web},
+			deployed: &deploymentRecord{Recipes: map[string]string{"web": "webservice@3"}},
+			wantErr:  "recipe web: deployed as webservice, cannot be changed to test-versioned",
+		},
+		{
+			name:     "invalid version",
+			recipes:  []Recipe{web},
+			deployed: &deploymentRecord{Recipes: map[string]string{"web": "test-versioned"}},
+			wantErr:  `recipe web: invalid recipe version "test-versioned"`,
+		},
+		{name: "unknown deployment of the first version", recipes: []Recipe{first, plain}, want: map[string][]int{}},
+		{
+			name:    "unknown deployment of a later version",
+			recipes: []Recipe{web},
+			wantErr: "recipe web: the deployed version is not recorded, upgrading to version 3 is unsafe",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := planRecipeMigrations(tt.recipes, tt.deployed)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := map[string][]int{}
+			for name, migrations := range plan {
+				got[name] = migrationVersions(migrations)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// Returns a recipe including other recipes.
+func nestingRecipe(name string, included ...Recipe) Recipe {
+	recipe := GetDefaultRecipe(name)
+	recipe.(NestingRecipe).Include(included...)
+
+	return recipe
+}
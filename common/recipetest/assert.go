@@ -0,0 +1,113 @@
+package recipetest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+)
+
+// Asserts that at least one resource of a type was registered, and returns the first one.
+func (r *Result) AssertResource(t testing.TB, typeToken string) Resource {
+	t.Helper()
+
+	resources := r.ResourcesOfType(typeToken)
+	if len(resources) == 0 {
+		t.Errorf("no resource of type %s registered, got %s", typeToken, r.describe())
+
+		return Resource{}
+	}
+
+	return resources[0]
+}
+
+// Asserts that a resource of a type with a logical name was registered, and returns it.
+func (r *Result) AssertNamedResource(t testing.TB, typeToken, name string) Resource {
+	t.Helper()
+
+	res, ok := r.Resource(typeToken, name)
+	if !ok {
+		t.Errorf("no resource %s of type %s registered, got %s", name, typeToken, r.describe())
+	}
+
+	return res
+}
+
+// Asserts that no resource of a type was registered.
+func (r *Result) AssertNoResource(t testing.TB, typeToken string) {
+	t.Helper()
+
+	if resources := r.ResourcesOfType(typeToken); len(resources) > 0 {
+		t.Errorf("expected no resource of type %s, got %d", typeToken, len(resources))
+	}
+}
+
+// Asserts that the number of resources of a type registered is count.
+func (r *Result) AssertResourceCount(t testing.TB, typeToken string, count int) {
+	t.Helper()
+
+	if resources := r.ResourcesOfType(typeToken); len(resources) != count {
+		t.Errorf("expected %d resource(s) of type %s, got %d", count, typeToken, len(resources))
+	}
+}
+
+// Asserts that a resource of a type was registered with an input at a property path like "tags.Name" equal to
+// expected, e.g. a string, a number, a bool, a []interface{} or a map[string]interface{}.
+func (r *Result) AssertProperty(t testing.TB, typeToken, path string, expected interface{}) {
+	t.Helper()
+
+	resources := r.ResourcesOfType(typeToken)
+	if len(resources) == 0 {
+		t.Errorf("no resource of type %s registered, got %s", typeToken, r.describe())
+
+		return
+	}
+
+	want := resource.NewPropertyValue(expected)
+	found := []string{}
+
+	for _, res := range resources {
+		value, ok := propertyAt(res.Inputs, path)
+		if !ok {
+			continue
+		}
+
+		if value.DeepEquals(want) {
+			return
+		}
+
+		found = append(found, fmt.Sprintf("%s: %v", res.Name, value.Mappable()))
+	}
+
+	if len(found) == 0 {
+		t.Errorf("no resource of type %s has the property %s", typeToken, path)
+
+		return
+	}
+
+	t.Errorf("no resource of type %s has the property %s = %v, got %v", typeToken, path, expected, found)
+}
+
+// Asserts that a resource of a type was registered with an input at a property path, whatever its value.
+func (r *Result) AssertHasProperty(t testing.TB, typeToken, path string) {
+	t.Helper()
+
+	for _, res := range r.ResourcesOfType(typeToken) {
+		if _, ok := propertyAt(res.Inputs, path); ok {
+			return
+		}
+	}
+
+	t.Errorf("no resource of type %s has the property %s", typeToken, path)
+}
+
+// Returns the registered resources like "[type name, ...]", for assertion messages.
+func (r *Result) describe() string {
+	described := make([]string, 0, len(r.Resources))
+	for _, res := range r.Resources {
+		described = append(described, res.Type+" "+res.Name)
+	}
+
+	return "[" + strings.Join(described, ", ") + "]"
+}
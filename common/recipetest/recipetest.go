@@ -0,0 +1,247 @@
+// Package recipetest runs recipes against Pulumi mocks, so that their Upsert logic can be unit tested without
+// any cloud account, state store or Pulumi CLI.
+//
+//	result, err := recipetest.Run([]common.Recipe{recipe}, recipetest.WithResourceOutputs("aws:s3/bucket:Bucket", map[string]interface{}{
+//		"arn": "arn:aws:s3:::my-bucket",
+//	}))
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//
+//	result.AssertProperty(t, "aws:s3/bucket:Bucket", "versioning.enabled", true)
+package recipetest
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"sourcesign.de/cloudprism/common"
+)
+
+// ResourceMock returns the ID and outputs of a mocked resource.
+type ResourceMock func(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error)
+
+// CallMock returns the result of a mocked provider function call, e.g. aws:index/getRegion:getRegion.
+type CallMock func(args pulumi.MockCallArgs) (resource.PropertyMap, error)
+
+// Option configures a run.
+type Option func(*mocks)
+
+// Runs with the given project and stack name, "cloudprism" and "test" by default.
+func WithStack(project, stack string) Option {
+	return func(m *mocks) {
+		m.project = project
+		m.stack = stack
+	}
+}
+
+// Runs the program with the given options, e.g. common.WithStandardTags, like the chef does.
+func WithProgramOptions(opts ...common.ProgramOption) Option {
+	return func(m *mocks) {
+		m.programOptions = append(m.programOptions, opts...)
+	}
+}
+
+// Mocks the resources of a type with outputs merged over their inputs.
+func WithResourceOutputs(typeToken string, outputs map[string]interface{}) Option {
+	return WithResourceMock(typeToken, func(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+		merged := args.Inputs.Copy()
+		for key, value := range resource.NewPropertyMapFromMap(outputs) {
+			merged[key] = value
+		}
+
+		return defaultResourceID(args), merged, nil
+	})
+}
+
+// Mocks the resources of a type with a function.
+func WithResourceMock(typeToken string, mock ResourceMock) Option {
+	return func(m *mocks) {
+		m.resourceMocks[typeToken] = mock
+	}
+}
+
+// Mocks the calls of a provider function with a function.
+func WithCallMock(token string, mock CallMock) Option {
+	return func(m *mocks) {
+		m.callMocks[token] = mock
+	}
+}
+
+// Resource is a resource registered by a recipe.
+type Resource struct {
+	Type    string               // The type token, e.g. "aws:s3/bucket:Bucket".
+	Name    string               // The logical name.
+	ID      string               // The physical ID returned by the mock.
+	Inputs  resource.PropertyMap // The inputs the resource was registered with.
+	Outputs resource.PropertyMap // The outputs returned by the mock.
+}
+
+// Call is a provider function called by a recipe.
+type Call struct {
+	Token string               // The function token, e.g. "aws:index/getRegion:getRegion".
+	Args  resource.PropertyMap // The arguments of the call.
+}
+
+// Result is what the recipes registered with Pulumi, in registration order.
+type Result struct {
+	Resources []Resource
+	Calls     []Call
+}
+
+// Runs the recipes, with their ingredients and included recipes, like the chef does, but against Pulumi mocks.
+// Unless mocked otherwise, resources return their inputs as outputs, and calls return their arguments.
+// The recipes are validated first, like before a real deployment.
+func Run(recipes []common.Recipe, opts ...Option) (*Result, error) {
+	if err := common.ValidateRecipes(recipes); err != nil {
+		return nil, err
+	}
+
+	m := &mocks{
+		project:       "cloudprism",
+		stack:         "test",
+		resourceMocks: map[string]ResourceMock{},
+		callMocks:     map[string]CallMock{},
+		result:        &Result{},
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	err := pulumi.RunErr(common.GetProgram(recipes, m.programOptions...), pulumi.WithMocks(m.project, m.stack, m))
+	if err != nil {
+		return m.result, fmt.Errorf("running recipes: %w", err)
+	}
+
+	return m.result, nil
+}
+
+// Returns all resources of a type.
+func (r *Result) ResourcesOfType(typeToken string) []Resource {
+	resources := []Resource{}
+
+	for _, res := range r.Resources {
+		if res.Type == typeToken {
+			resources = append(resources, res)
+		}
+	}
+
+	return resources
+}
+
+// Returns the resource of a type with a logical name, and whether it exists.
+func (r *Result) Resource(typeToken, name string) (Resource, bool) {
+	for _, res := range r.ResourcesOfType(typeToken) {
+		if res.Name == name {
+			return res, true
+		}
+	}
+
+	return Resource{}, false
+}
+
+// Returns an input of the resource at a property path like "tags.Name" or "rules[0].port", and whether it exists.
+// Secrets are returned revealed.
+func (res Resource) Input(path string) (interface{}, bool) {
+	value, ok := propertyAt(res.Inputs, path)
+	if !ok {
+		return nil, false
+	}
+
+	return value.Mappable(), true
+}
+
+type mocks struct {
+	project        string                  // The Pulumi project name.
+	stack          string                  // The Pulumi stack name.
+	resourceMocks  map[string]ResourceMock // The resource mocks by type token.
+	callMocks      map[string]CallMock     // The call mocks by function token.
+	programOptions []common.ProgramOption  // The options of the program.
+	mutex          sync.Mutex              // Guards result, Pulumi registers resources concurrently.
+	result         *Result                 // What was registered so far.
+}
+
+// NewResource implements pulumi.MockResourceMonitor.
+func (m *mocks) NewResource(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+	id, outputs := defaultResourceID(args), args.Inputs
+
+	if mock, ok := m.resourceMocks[args.TypeToken]; ok {
+		var err error
+
+		id, outputs, err = mock(args)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.result.Resources = append(m.result.Resources, Resource{
+		Type:    args.TypeToken,
+		Name:    args.Name,
+		ID:      id,
+		Inputs:  args.Inputs,
+		Outputs: outputs,
+	})
+
+	return id, outputs, nil
+}
+
+// Call implements pulumi.MockResourceMonitor.
+func (m *mocks) Call(args pulumi.MockCallArgs) (resource.PropertyMap, error) {
+	m.mutex.Lock()
+	m.result.Calls = append(m.result.Calls, Call{Token: args.Token, Args: args.Args})
+	m.mutex.Unlock()
+
+	if mock, ok := m.callMocks[args.Token]; ok {
+		return mock(args)
+	}
+
+	return args.Args, nil
+}
+
+// Returns the ID of a read or imported resource, or one derived from its name.
+func defaultResourceID(args pulumi.MockResourceArgs) string {
+	if args.ID != "" {
+		return args.ID
+	}
+
+	return args.Name + "_id"
+}
+
+// Returns the value at a property path, with all secrets revealed.
+func propertyAt(properties resource.PropertyMap, path string) (resource.PropertyValue, bool) {
+	parsed, err := resource.ParsePropertyPath(path)
+	if err != nil {
+		return resource.PropertyValue{}, false
+	}
+
+	return parsed.Get(revealSecrets(resource.NewObjectProperty(properties)))
+}
+
+func revealSecrets(value resource.PropertyValue) resource.PropertyValue {
+	switch {
+	case value.IsSecret():
+		return revealSecrets(value.SecretValue().Element)
+	case value.IsArray():
+		revealed := make([]resource.PropertyValue, 0, len(value.ArrayValue()))
+		for _, element := range value.ArrayValue() {
+			revealed = append(revealed, revealSecrets(element))
+		}
+
+		return resource.NewArrayProperty(revealed)
+	case value.IsObject():
+		revealed := resource.PropertyMap{}
+		for key, element := range value.ObjectValue() {
+			revealed[key] = revealSecrets(element)
+		}
+
+		return resource.NewObjectProperty(revealed)
+	default:
+		return value
+	}
+}
@@ -0,0 +1,254 @@
+package recipetest_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"sourcesign.de/cloudprism/common"
+	"sourcesign.de/cloudprism/common/recipetest"
+)
+
+const (
+	thingType  = "test:index:Thing"
+	regionCall = "test:index:getRegion"
+)
+
+type thing struct {
+	Name string            `pulumi:"name"`
+	Size int               `pulumi:"size"`
+	Tags map[string]string `pulumi:"tags"`
+}
+
+// thingArgs are the arguments of a taggable test resource, like the ones of the provider SDKs.
+type thingArgs struct {
+	Name pulumi.StringInput
+	Size pulumi.IntInput
+	Tags pulumi.StringMapInput
+}
+
+func (thingArgs) ElementType() reflect.Type {
+	return reflect.TypeOf((*thing)(nil)).Elem()
+}
+
+type thingResource struct {
+	pulumi.CustomResourceState
+
+	Arn pulumi.StringOutput `pulumi:"arn"`
+}
+
+// thingIngredient registers a thing per name, and calls a provider function if call is set.
+type thingIngredient struct {
+	names  []string
+	call   bool
+	region string
+	arns   []pulumi.StringOutput
+}
+
+func (ti *thingIngredient) Upsert(ctx *pulumi.Context) error {
+	if ti.call {
+		var result struct {
+			Name string `pulumi:"name"`
+		}
+
+		if err := ctx.Invoke(regionCall, map[string]interface{}{"name": "default"}, &result); err != nil {
+			return err
+		}
+
+		ti.region = result.Name
+	}
+
+	for _, name := range ti.names {
+		res := &thingResource{}
+
+		err := ctx.RegisterResource(thingType, name, &thingArgs{
+			Name: pulumi.String(name),
+			Size: pulumi.Int(len(name)),
+			Tags: pulumi.StringMap{"Name": pulumi.String(name)},
+		}, res)
+		if err != nil {
+			return err
+		}
+
+		ti.arns = append(ti.arns, res.Arn)
+	}
+
+	return nil
+}
+
+func (ti *thingIngredient) Result() interface{} {
+	return ti.arns
+}
+
+func recipeWith(ingredients ...common.Ingredient) []common.Recipe {
+	recipe := common.GetDefaultRecipe("things")
+	recipe.Append(ingredients...)
+
+	return []common.Recipe{recipe}
+}
+
+func TestRunRecordsResources(t *testing.T) {
+	result, err := recipetest.Run(recipeWith(&thingIngredient{names: []string{"first", "second"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result.AssertResourceCount(t, thingType, 2)
+	result.AssertNoResource(t, "test:index:Other")
+
+	res := result.AssertNamedResource(t, thingType, "second")
+	if res.ID != "second_id" {
+		t.Errorf("expected the ID derived from the name, got %q", res.ID)
+	}
+
+	result.AssertProperty(t, thingType, "name", "first")
+	result.AssertProperty(t, thingType, "size", 6)
+	result.AssertProperty(t, thingType, "tags.Name", "second")
+	result.AssertHasProperty(t, thingType, "tags")
+
+	tests := []struct {
+		path   string
+		want   interface{}
+		exists bool
+	}{
+		{path: "name", want: "second", exists: true},
+		{path: "size", want: float64(6), exists: true},
+		{path: "tags.Name", want: "second", exists: true},
+		{path: "tags", want: map[string]interface{}{"Name": "second"}, exists: true},
+		{path: "missing"},
+		{path: "tags.Missing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			value, ok := res.Input(tt.path)
+			if ok != tt.exists {
+				t.Fatalf("expected the input to exist: %v, got %v", tt.exists, ok)
+			}
+
+			if ok && !reflect.DeepEqual(value, tt.want) {
+				t.Errorf("expected %#v, got %#v", tt.want, value)
+			}
+		})
+	}
+}
+
+func TestRunWithResourceOutputs(t *testing.T) {
+	ingredient := &thingIngredient{names: []string{"first"}}
+
+	result, err := recipetest.Run(recipeWith(ingredient), recipetest.WithResourceOutputs(thingType, map[string]interface{}{
+		"arn": "arn:test:first",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := result.AssertNamedResource(t, thingType, "first")
+	if arn := res.Outputs["arn"]; !arn.IsString() || arn.StringValue() != "arn:test:first" {
+		t.Errorf("expected the mocked arn output, got %v", arn)
+	}
+
+	if name := res.Outputs["name"]; !name.IsString() || name.StringValue() != "first" {
+		t.Errorf("expected the inputs to be kept as outputs, got %v", name)
+	}
+}
+
+func TestRunWithResourceMockError(t *testing.T) {
+	_, err := recipetest.Run(recipeWith(&thingIngredient{names: []string{"first"}}),
+		recipetest.WithResourceMock(thingType, func(pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+			return "", nil, errors.New("quota exceeded")
+		}))
+	if err == nil || !strings.Contains(err.Error(), "quota exceeded") {
+		t.Errorf("expected the mock error, got %v", err)
+	}
+}
+
+func TestRunWithCallMock(t *testing.T) {
+	ingredient := &thingIngredient{call: true}
+
+	result, err := recipetest.Run(recipeWith(ingredient),
+		recipetest.WithCallMock(regionCall, func(pulumi.MockCallArgs) (resource.PropertyMap, error) {
+			return resource.NewPropertyMapFromMap(map[string]interface{}{"name": "eu-central-1"}), nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ingredient.region != "eu-central-1" {
+		t.Errorf("expected the mocked region, got %q", ingredient.region)
+	}
+
+	if len(result.Calls) != 1 || result.Calls[0].Token != regionCall {
+		t.Errorf("expected one call of %s, got %v", regionCall, result.Calls)
+	}
+}
+
+func TestRunWithProgramOptions(t *testing.T) {
+	result, err := recipetest.Run(recipeWith(&thingIngredient{names: []string{"first"}}),
+		recipetest.WithProgramOptions(common.WithStandardTags(map[string]string{"Environment": "dev"})))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result.AssertProperty(t, thingType, "tags.Environment", "dev")
+	result.AssertProperty(t, thingType, "tags.Name", "first")
+}
+
+func TestRunValidatesRecipes(t *testing.T) {
+	recipes := append(recipeWith(&thingIngredient{}), recipeWith(&thingIngredient{})...)
+
+	result, err := recipetest.Run(recipes)
+	if err == nil {
+		t.Fatal("expected recipes with the same name to be refused")
+	}
+
+	if result != nil {
+		t.Errorf("expected nothing to run, got %v", result.Resources)
+	}
+}
+
+func TestAssertionsReportFailures(t *testing.T) {
+	result, err := recipetest.Run(recipeWith(&thingIngredient{names: []string{"first"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		assert func(t testing.TB)
+	}{
+		{"missing type", func(t testing.TB) { result.AssertResource(t, "test:index:Other") }},
+		{"missing name", func(t testing.TB) { result.AssertNamedResource(t, thingType, "second") }},
+		{"unexpected type", func(t testing.TB) { result.AssertNoResource(t, thingType) }},
+		{"wrong count", func(t testing.TB) { result.AssertResourceCount(t, thingType, 2) }},
+		{"wrong value", func(t testing.TB) { result.AssertProperty(t, thingType, "name", "second") }},
+		{"missing property", func(t testing.TB) { result.AssertProperty(t, thingType, "tags.Missing", "x") }},
+		{"missing any property", func(t testing.TB) { result.AssertHasProperty(t, thingType, "missing") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &failureRecorder{TB: t}
+			tt.assert(recorder)
+
+			if !recorder.failed {
+				t.Error("expected the assertion to fail")
+			}
+		})
+	}
+}
+
+// failureRecorder records failed assertions instead of failing the test.
+type failureRecorder struct {
+	testing.TB
+	failed bool
+}
+
+func (r *failureRecorder) Helper() {}
+
+func (r *failureRecorder) Errorf(string, ...interface{}) {
+	r.failed = true
+}
@@ -0,0 +1,291 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/apex/log"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"gopkg.in/yaml.v3"
+)
+
+// TemplateConfig is an entry of the "templates" section of rapid.yaml: a Go text/template rendered with the
+// outputs of the stack, e.g. an application config, Helm values or an nginx config.
+type TemplateConfig struct {
+	Template string `mapstructure:"template" yaml:"template"` // The template file.
+	Output   string `mapstructure:"output" yaml:"output"`     // The file written, replaced if it exists.
+}
+
+// RenderedTemplate is a template rendered with the outputs of a stack, not written yet.
+type RenderedTemplate struct {
+	Output  string // The file to write.
+	Content []byte // The rendered content.
+	Secret  bool   // True if the content depends on secret outputs, the file is then only readable by its owner.
+}
+
+// Renders templates with the outputs of a stack. The outputs are the data of the templates, e.g. {{ .web.url }},
+// and are also available with the output function, e.g. {{ output "web.url" }}. Rendering fails if a template
+// references a missing output. Errors never contain output values.
+//
+// A template reading secret outputs is rendered secret. To find out, templates are executed a second time with
+// every secret output changed: the template is secret if its content changes or the second execution fails.
+func RenderTemplates(templates []TemplateConfig, outputs auto.OutputMap) ([]RenderedTemplate, error) {
+	data := outputValues(outputs)
+	changed := changedSecretOutputValues(outputs)
+	rendered := make([]RenderedTemplate, 0, len(templates))
+
+	for _, cfg := range templates {
+		if cfg.Template == "" || cfg.Output == "" {
+			return nil, fmt.Errorf("templates: both template and output must be set")
+		}
+
+		text, err := os.ReadFile(cfg.Template)
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := executeTemplate(cfg.Template, string(text), data)
+		if err != nil {
+			return nil, fmt.Errorf("template %s: %w", cfg.Template, err)
+		}
+
+		secret := false
+
+		if changed != nil {
+			other, err := executeTemplate(cfg.Template, string(text), changed)
+			secret = err != nil || !bytes.Equal(content, other)
+		}
+
+		rendered = append(rendered, RenderedTemplate{Output: cfg.Output, Content: content, Secret: secret})
+	}
+
+	return rendered, nil
+}
+
+// Executes a template with the outputs of a stack as data.
+func executeTemplate(file, text string, data map[string]interface{}) ([]byte, error) {
+	tmpl, err := template.New(filepath.Base(file)).
+		Option("missingkey=error").
+		Funcs(templateFuncs(data)).
+		Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &bytes.Buffer{}
+	if err := tmpl.Execute(out, data); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+// Writes a rendered template, creating the directory of the file if needed.
+func (r RenderedTemplate) Write() error {
+	mode := os.FileMode(0o644)
+	if r.Secret {
+		mode = 0o600
+	}
+
+	dir := filepath.Dir(r.Output)
+	if err := os.MkdirAll(dir, 0o755); err != nil { // #nosec G301 -- the output directory is chosen by the user
+		return err
+	}
+
+	// The content is written to a temporary file only readable by its owner, which then replaces the output, so
+	// secrets are never readable by others, not even in a file existing before.
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(r.Output)+".*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(r.Content); err != nil {
+		tmp.Close()
+
+		return err
+	}
+
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), r.Output)
+}
+
+// Returns the functions available in templates.
+func templateFuncs(data map[string]interface{}) template.FuncMap {
+	return template.FuncMap{
+		"output": func(path string) (interface{}, error) {
+			return lookupOutput(data, path)
+		},
+		"default": func(fallback, value interface{}) interface{} {
+			if value == nil || value == "" {
+				return fallback
+			}
+
+			return value
+		},
+		"toJson": func(value interface{}) (string, error) {
+			encoded, err := json.Marshal(value)
+
+			return string(encoded), err
+		},
+		"toYaml": func(value interface{}) (string, error) {
+			encoded, err := yaml.Marshal(value)
+
+			return strings.TrimSuffix(string(encoded), "\n"), err
+		},
+		"quote": func(value interface{}) string {
+			encoded, _ := json.Marshal(fmt.Sprint(value))
+
+			return string(encoded)
+		},
+		"indent": func(spaces int, text string) string {
+			padding := strings.Repeat(" ", spaces)
+
+			return padding + strings.ReplaceAll(text, "\n", "\n"+padding)
+		},
+		"b64enc": func(text string) string {
+			return base64.StdEncoding.EncodeToString([]byte(text))
+		},
+		"join": func(separator string, values []interface{}) string {
+			texts := make([]string, 0, len(values))
+			for _, value := range values {
+				texts = append(texts, fmt.Sprint(value))
+			}
+
+			return strings.Join(texts, separator)
+		},
+		"upper":     strings.ToUpper,
+		"lower":     strings.ToLower,
+		"trim":      strings.TrimSpace,
+		"replace":   func(old, replacement, text string) string { return strings.ReplaceAll(text, old, replacement) },
+		"split":     func(separator, text string) []string { return strings.Split(text, separator) },
+		"hasPrefix": func(prefix, text string) bool { return strings.HasPrefix(text, prefix) },
+		"hasSuffix": func(suffix, text string) bool { return strings.HasSuffix(text, suffix) },
+	}
+}
+
+// Returns the output at a dotted path like "web.url", nested outputs being maps.
+func lookupOutput(data map[string]interface{}, path string) (interface{}, error) {
+	var value interface{} = data
+
+	for _, key := range strings.Split(path, ".") {
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("output %q is missing", path)
+		}
+
+		value, ok = values[key]
+		if !ok {
+			return nil, fmt.Errorf("output %q is missing", path)
+		}
+	}
+
+	return value, nil
+}
+
+// Returns the values of the outputs with every string, number and bool of the secret outputs changed, nil if no
+// output is secret.
+func changedSecretOutputValues(outputs auto.OutputMap) map[string]interface{} {
+	values := outputValues(outputs)
+	secret := false
+
+	for name, output := range outputs {
+		if output.Secret {
+			values[name] = changeValue(output.Value)
+			secret = true
+		}
+	}
+
+	if !secret {
+		return nil
+	}
+
+	return values
+}
+
+// Returns a value with every string, number and bool changed, including the ones nested in maps and lists.
+func changeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return v + "\x00"
+	case float64:
+		// Adding to large numbers may not change them, negating changes all but zero.
+		if v == 0 {
+			return 1.0
+		}
+
+		return -v
+	case bool:
+		return !v
+	case map[string]interface{}:
+		changed := make(map[string]interface{}, len(v))
+		for key, nested := range v {
+			changed[key] = changeValue(nested)
+		}
+
+		return changed
+	case []interface{}:
+		changed := make([]interface{}, 0, len(v))
+		for _, nested := range v {
+			changed = append(changed, changeValue(nested))
+		}
+
+		return changed
+	default:
+		return v
+	}
+}
+
+// Render implements Chef. All templates are rendered before any file is written.
+func (dc *defaultChef) Render(templates []TemplateConfig) error {
+	ctx := context.Background()
+
+	stack, err := dc.getStack(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	outputs, err := stack.Outputs(ctx)
+	if err != nil {
+		log.WithFields(dc.fields()).WithError(err).Error("DefaultChef reading outputs failed")
+
+		return err
+	}
+
+	rendered, err := RenderTemplates(templates, outputs)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range rendered {
+		if err := r.Write(); err != nil {
+			log.WithFields(dc.fields()).WithField("output", r.Output).WithError(err).Error("DefaultChef writing rendered template failed")
+
+			return err
+		}
+
+		log.WithFields(dc.fields()).WithFields(log.Fields{
+			"output": r.Output,
+			"secret": r.Secret,
+		}).Info("Rendered template")
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+)
+
+func TestRenderTemplates(t *testing.T) {
+	outputs := auto.OutputMap{
+		"web": {Value: map[string]interface{}{"url": "https://shop.example.com", "replicas": float64(2)}},
+		"db": {Value: map[string]interface{}{
+			"host":     "db.internal",
+			"password": "hunter2",
+			"tls":      true,
+			"port":     float64(5432),
+			"serial":   float64(1 << 60),
+			"zero":     float64(0),
+			"users":    []interface{}{"app", "admin"},
+		}, Secret: true},
+	}
+
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		secret  bool
+		wantErr string
+	}{
+		{name: "plain output", text: "url: {{ .web.url }}", want: "url: https://shop.example.com"},
+		{name: "plain output with function", text: `url: {{ output "web.url" | upper }}`, want: "url: HTTPS://SHOP.EXAMPLE.COM"},
+		{name: "secret output", text: "password: {{ .db.password }}", want: "password: hunter2", secret: true},
+		{name: "secret output with function", text: `password: {{ output "db.password" }}`, want: "password: hunter2", secret: true},
+		{name: "encoded secret output", text: "password: {{ .db.password | b64enc }}", want: "password: aHVudGVyMg==", secret: true},
+		{name: "transformed secret output", text: `host: {{ replace "." "-" .db.host }}`, want: "host: db-internal", secret: true},
+		{name: "secret number", text: "port: {{ .db.port }}", want: "port: 5432", secret: true},
+		{name: "large secret number", text: "serial: {{ .db.serial }}", want: "serial: 1.152921504606847e+18", secret: true},
+		{name: "secret zero", text: "retries: {{ .db.zero }}", want: "retries: 0", secret: true},
+		{name: "secret bool", text: "{{ if .db.tls }}sslmode: require{{ end }}", want: "sslmode: require", secret: true},
+		{name: "secret list", text: `users: {{ join "," .db.users }}`, want: "users: app,admin", secret: true},
+		{name: "all outputs", text: "{{ toJson . }}", want: `"password":"hunter2"`, secret: true},
+		{name: "secret only tested for presence", text: "{{ if .db.password }}auth: on{{ end }}", want: "auth: on"},
+		{name: "missing output", text: "{{ .web.missing }}", wantErr: "missing"},
+		{name: "missing output with function", text: `{{ output "db.missing" }}`, wantErr: `output "db.missing" is missing`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "config.tmpl")
+			if err := os.WriteFile(file, []byte(tt.text), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			rendered, err := RenderTemplates([]TemplateConfig{{Template: file, Output: "config.yaml"}}, outputs)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+
+				if strings.Contains(err.Error(), "hunter2") {
+					t.Errorf("the error contains a secret: %v", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if content := string(rendered[0].Content); !strings.Contains(content, tt.want) {
+				t.Errorf("expected the content to contain %q, got %q", tt.want, content)
+			}
+
+			if rendered[0].Secret != tt.secret {
+				t.Errorf("expected secret %v, got %v", tt.secret, rendered[0].Secret)
+			}
+		})
+	}
+}
+
+func TestRenderTemplatesWithoutSecretOutputs(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.tmpl")
+	if err := os.WriteFile(file, []byte("{{ toJson . }}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	rendered, err := RenderTemplates([]TemplateConfig{{Template: file, Output: "config.json"}},
+		auto.OutputMap{"web": {Value: map[string]interface{}{"url": "https://shop.example.com"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rendered[0].Secret {
+		t.Error("expected a template without secret outputs not to be secret")
+	}
+}
+
+func TestRenderedTemplateWrite(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret bool
+		mode   os.FileMode
+	}{
+		{name: "plain", mode: 0o644},
+		{name: "secret", secret: true, mode: 0o600},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			output := filepath.Join(dir, "config", "app.yaml")
+
+			if err := os.MkdirAll(filepath.Dir(output), 0o755); err != nil {
+				t.Fatal(err)
+			}
+
+			// An existing file readable by others is replaced, not written to.
+			if err := os.WriteFile(output, []byte("old"), 0o644); err != nil { // #nosec G306 -- the mode is what is tested
+				t.Fatal(err)
+			}
+
+			if err := (RenderedTemplate{Output: output, Content: []byte("password: hunter2"), Secret: tt.secret}).Write(); err != nil {
+				t.Fatal(err)
+			}
+
+			info, err := os.Stat(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if info.Mode().Perm() != tt.mode {
+				t.Errorf("expected mode %v, got %v", tt.mode, info.Mode().Perm())
+			}
+
+			if content, _ := os.ReadFile(output); string(content) != "password: hunter2" {
+				t.Errorf("unexpected content %q", content)
+			}
+
+			if entries, _ := os.ReadDir(filepath.Dir(output)); len(entries) != 1 {
+				t.Errorf("expected only the output in its directory, got %d entries", len(entries))
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// ResourcePolicy holds the default resource options of an application environment, applied to all resources.
+type ResourcePolicy struct {
+	Protect        bool          // Refuse to delete the resources.
+	RetainOnDelete bool          // Keep the cloud resources when they are deleted from the stack.
+	DeleteTimeout  time.Duration // The time allowed to delete a resource, 0 for the provider default.
+}
+
+// ResourcePolicyConfig is an entry of the "resourcePolicies" section of rapid.yaml, keyed by environment ID.
+// Unset values keep the defaults of the environment.
+type ResourcePolicyConfig struct {
+	Protect        *bool  `mapstructure:"protect" yaml:"protect"`
+	RetainOnDelete *bool  `mapstructure:"retainOnDelete" yaml:"retainOnDelete"`
+	DeleteTimeout  string `mapstructure:"deleteTimeout" yaml:"deleteTimeout"` // A duration like "45m".
+}
+
+// ResourcePolicyOptOut lists the parts of the resource policy an ingredient opts out of, and why.
+type ResourcePolicyOptOut struct {
+	Protect        bool   `mapstructure:"protect" yaml:"protect"`
+	RetainOnDelete bool   `mapstructure:"retainOnDelete" yaml:"retainOnDelete"`
+	DeleteTimeout  bool   `mapstructure:"deleteTimeout" yaml:"deleteTimeout"`
+	Reason         string `mapstructure:"reason" yaml:"reason"`
+}
+
+// PolicyOptOutIngredient is implemented by ingredients whose resources must not follow parts of the resource
+// policy, e.g. caches that are recreated on purpose. Every opted out resource is logged.
+type PolicyOptOutIngredient interface {
+	ResourcePolicyOptOut() ResourcePolicyOptOut
+}
+
+// Returns the resource policy of an application environment: resources are protected and retained on delete
+// following the protection level of the environment, e.g. in production only, unless configured otherwise.
+// The resources of applications processing sensitive data are retained on delete in every environment. Restricted
+// data raises the protection level by one instead, so resources are protected where the environment retains them
+// already, but may still be taken down with `down` in environments without protection. The metadata may be nil.
+func GetResourcePolicy(env ApplicationEnvironment, cfgs map[string]ResourcePolicyConfig, metadata *ApplicationMetadata) (ResourcePolicy, error) {
+	policy := ResourcePolicy{}
+
+	switch env.Protection() {
+	case ProtectionProtect:
+		policy.Protect = true
+		policy.RetainOnDelete = true
+	case ProtectionRetain:
+		policy.RetainOnDelete = true
+	case ProtectionNone:
+	}
+
+	if metadata != nil && metadata.Sensitive() {
+		policy.RetainOnDelete = true
+		policy.Protect = policy.Protect ||
+			(metadata.DataClassification == DataRestricted && env.Protection() == ProtectionRetain)
+	}
+
+	cfg, ok := cfgs[env.ID()]
+	if !ok {
+		return policy, nil
+	}
+
+	if cfg.Protect != nil {
+		policy.Protect = *cfg.Protect
+	}
+
+	if cfg.RetainOnDelete != nil {
+		policy.RetainOnDelete = *cfg.RetainOnDelete
+	}
+
+	if cfg.DeleteTimeout != "" {
+		timeout, err := time.ParseDuration(cfg.DeleteTimeout)
+		if err != nil || timeout <= 0 {
+			return ResourcePolicy{}, fmt.Errorf("resource policy of %s: invalid delete timeout %q", env.ID(), cfg.DeleteTimeout)
+		}
+
+		policy.DeleteTimeout = timeout
+	}
+
+	return policy, nil
+}
+
+// Applies the resource policy to all custom resources. Options set on a resource itself take precedence.
+func WithResourcePolicy(policy ResourcePolicy) ProgramOption {
+	return func(opts *programOptions) {
+		opts.policy = policy
+	}
+}
+
+// A stack transformation adding the options of the resource policy to a custom resource, minus the ones the
+// ingredient being upserted opts out of.
+func (ps *programState) applyResourcePolicy(args *pulumi.ResourceTransformationArgs) *pulumi.ResourceTransformationResult {
+	if _, custom := args.Resource.(pulumi.CustomResource); !custom {
+		return nil
+	}
+
+	policy := ps.options.policy
+	optOut := ResourcePolicyOptOut{}
+
+	if ps.ingredient != nil {
+		if opting, ok := ingredientAs[PolicyOptOutIngredient](ps.ingredient); ok {
+			optOut = opting.ResourcePolicyOptOut()
+		}
+	}
+
+	opts := []pulumi.ResourceOption{}
+	optedOut := []string{}
+
+	if policy.Protect {
+		if optOut.Protect {
+			optedOut = append(optedOut, "protect")
+		} else {
+			opts = append(opts, pulumi.Protect(true))
+		}
+	}
+
+	if policy.RetainOnDelete {
+		if optOut.RetainOnDelete {
+			optedOut = append(optedOut, "retainOnDelete")
+		} else {
+			opts = append(opts, pulumi.RetainOnDelete(true))
+		}
+	}
+
+	if policy.DeleteTimeout > 0 {
+		if optOut.DeleteTimeout {
+			optedOut = append(optedOut, "deleteTimeout")
+		} else {
+			opts = append(opts, pulumi.Timeouts(&pulumi.CustomTimeouts{Delete: policy.DeleteTimeout.String()}))
+		}
+	}
+
+	if len(optedOut) > 0 {
+		log.WithFields(log.Fields{
+			"ingredient": IngredientName(ps.ingredient),
+			"resource":   args.Name,
+			"type":       args.Type,
+			"optOut":     strings.Join(optedOut, ","),
+			"reason":     optOut.Reason,
+		}).Warn("Resource opts out of the resource policy")
+	}
+
+	if len(opts) == 0 {
+		return nil
+	}
+
+	return &pulumi.ResourceTransformationResult{
+		Props: args.Props,
+		Opts:  append(opts, args.Opts...),
+	}
+}
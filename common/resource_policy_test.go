@@ -0,0 +1,75 @@
+package common
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetResourcePolicy(t *testing.T) {
+	t.Cleanup(resetEnvironments(t))
+
+	if err := ConfigureEnvironments([]EnvironmentConfig{{ID: "stg", Name: "Staging", Protection: ProtectionRetain}}); err != nil {
+		t.Fatal(err)
+	}
+
+	staging, err := ParseApplicationEnvironment("stg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	disabled, enabled := false, true
+
+	tests := []struct {
+		name           string
+		env            ApplicationEnvironment
+		cfgs           map[string]ResourcePolicyConfig
+		classification DataClassification
+		want           ResourcePolicy
+		wantErr        bool
+	}{
+		{name: "development", env: AppEnvDevelopment},
+		{name: "production", env: AppEnvProduction, want: ResourcePolicy{Protect: true, RetainOnDelete: true}},
+		{name: "internal data", env: AppEnvDevelopment, classification: DataInternal},
+		{name: "confidential data", env: AppEnvDevelopment, classification: DataConfidential, want: ResourcePolicy{RetainOnDelete: true}},
+		{name: "restricted data", env: AppEnvDevelopment, classification: DataRestricted, want: ResourcePolicy{RetainOnDelete: true}},
+		{
+			name:           "restricted data retained",
+			env:            staging,
+			classification: DataRestricted,
+			want:           ResourcePolicy{Protect: true, RetainOnDelete: true},
+		},
+		{name: "confidential data retained", env: staging, classification: DataConfidential, want: ResourcePolicy{RetainOnDelete: true}},
+		{
+			name:           "configured over the data classification",
+			env:            staging,
+			cfgs:           map[string]ResourcePolicyConfig{"stg": {Protect: &disabled, DeleteTimeout: "45m"}},
+			classification: DataRestricted,
+			want:           ResourcePolicy{RetainOnDelete: true, DeleteTimeout: 45 * time.Minute},
+		},
+		{
+			name: "configured over the environment",
+			env:  AppEnvProduction,
+			cfgs: map[string]ResourcePolicyConfig{"prd": {RetainOnDelete: &disabled}, "dev": {Protect: &enabled}},
+			want: ResourcePolicy{Protect: true},
+		},
+		{name: "invalid delete timeout", env: AppEnvDevelopment, cfgs: map[string]ResourcePolicyConfig{"dev": {DeleteTimeout: "soon"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var metadata *ApplicationMetadata
+			if tt.classification != "" {
+				metadata = &ApplicationMetadata{DataClassification: tt.classification}
+			}
+
+			got, err := GetResourcePolicy(tt.env, tt.cfgs, metadata)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected an error: %v, got %v", tt.wantErr, err)
+			}
+
+			if got != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
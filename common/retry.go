@@ -0,0 +1,172 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// The longest time waited before a retry if the policy sets none.
+const defaultMaxBackoff = 2 * time.Minute
+
+// The errors of cloud APIs retried by default: throttling, unavailable services and eventual consistency, e.g. IAM
+// roles not propagated yet.
+// nolint: gochecknoglobals
+var defaultTransientErrorMatchers = []string{
+	`(?i)throttl`,
+	`(?i)rate exceeded`,
+	`(?i)too many requests`,
+	`RequestLimitExceeded`,
+	`(?i)service unavailable`,
+	`(?i)connection reset by peer`,
+	`cannot be assumed by Lambda`,
+	`(?i)role .* (cannot be assumed|is not authorized to perform: sts:AssumeRole)`,
+	`(?i)eventual(ly)? consisten`,
+	`(?i)operation already in progress`,
+	`ConcurrentModificationException`,
+}
+
+// RetryPolicy re-runs an update failing with an error classified as transient, with exponential backoff.
+// The zero value does not retry.
+type RetryPolicy struct {
+	Attempts       int              // The number of attempts, including the first one.
+	InitialBackoff time.Duration    // The time waited before the first retry.
+	MaxBackoff     time.Duration    // The longest time waited before a retry, 2 minutes if not set.
+	Multiplier     float64          // The factor the backoff grows by after each retry.
+	Matchers       []*regexp.Regexp // An error is transient if one of them matches its message.
+}
+
+// RetryPolicyConfig is the "retry" section of rapid.yaml. Unset values take the defaults.
+type RetryPolicyConfig struct {
+	Attempts       int      `mapstructure:"attempts" yaml:"attempts"`             // Defaults to 1, i.e. no retries.
+	InitialBackoff string   `mapstructure:"initialBackoff" yaml:"initialBackoff"` // A duration like "10s".
+	MaxBackoff     string   `mapstructure:"maxBackoff" yaml:"maxBackoff"`         // A duration like "2m".
+	Multiplier     float64  `mapstructure:"multiplier" yaml:"multiplier"`         // Defaults to 2.
+	Matchers       []string `mapstructure:"matchers" yaml:"matchers"`             // Regular expressions, added to the defaults.
+}
+
+// Returns the retry policy of a configuration.
+func GetRetryPolicy(cfg RetryPolicyConfig) (RetryPolicy, error) {
+	policy := RetryPolicy{
+		Attempts:       1,
+		InitialBackoff: 10 * time.Second,
+		MaxBackoff:     defaultMaxBackoff,
+		Multiplier:     2,
+	}
+
+	if cfg.Attempts < 0 {
+		return RetryPolicy{}, fmt.Errorf("retry policy: invalid attempts %d", cfg.Attempts)
+	}
+
+	if cfg.Attempts > 0 {
+		policy.Attempts = cfg.Attempts
+	}
+
+	if cfg.Multiplier < 0 || (cfg.Multiplier > 0 && cfg.Multiplier < 1) {
+		return RetryPolicy{}, fmt.Errorf("retry policy: invalid multiplier %v, must be at least 1", cfg.Multiplier)
+	}
+
+	if cfg.Multiplier > 0 {
+		policy.Multiplier = cfg.Multiplier
+	}
+
+	for _, backoff := range []struct {
+		value  string
+		target *time.Duration
+	}{
+		{cfg.InitialBackoff, &policy.InitialBackoff},
+		{cfg.MaxBackoff, &policy.MaxBackoff},
+	} {
+		if backoff.value == "" {
+			continue
+		}
+
+		duration, err := time.ParseDuration(backoff.value)
+		if err != nil || duration < 0 {
+			return RetryPolicy{}, fmt.Errorf("retry policy: invalid backoff %q", backoff.value)
+		}
+
+		*backoff.target = duration
+	}
+
+	for _, matcher := range append(append([]string{}, defaultTransientErrorMatchers...), cfg.Matchers...) {
+		compiled, err := regexp.Compile(matcher)
+		if err != nil {
+			return RetryPolicy{}, fmt.Errorf("retry policy: invalid matcher %q: %w", matcher, err)
+		}
+
+		policy.Matchers = append(policy.Matchers, compiled)
+	}
+
+	return policy, nil
+}
+
+// Retries the updates of the chef following the retry policy.
+func WithRetryPolicy(policy RetryPolicy) ChefOption {
+	return func(co *chefOptions) {
+		co.retry = policy
+	}
+}
+
+// Returns the part of the error message classifying an error as transient, or false if it is not.
+func (p RetryPolicy) transient(err error) (string, bool) {
+	for _, matcher := range p.Matchers {
+		if reason := matcher.FindString(err.Error()); reason != "" {
+			return reason, true
+		}
+	}
+
+	return "", false
+}
+
+// Returns the time waited before a retry, starting at 1, never longer than the maximum backoff.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	maxBackoff := p.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+
+	backoff := math.Min(float64(p.InitialBackoff), float64(maxBackoff))
+	for i := 1; i < retry && backoff < float64(maxBackoff); i++ {
+		backoff = math.Min(backoff*p.Multiplier, float64(maxBackoff))
+	}
+
+	return time.Duration(backoff)
+}
+
+// Runs an operation of the chef, and runs it again while it fails with a transient error and attempts are left.
+func (dc *defaultChef) retry(ctx context.Context, operation string, run func() error) error {
+	policy := dc.options.retry
+
+	for attempt := 1; ; attempt++ {
+		err := run()
+		if err == nil || attempt >= policy.Attempts {
+			return err
+		}
+
+		reason, transient := policy.transient(err)
+		if !transient {
+			return err
+		}
+
+		backoff := policy.backoff(attempt)
+
+		log.WithFields(dc.fields()).WithFields(log.Fields{
+			"operation": operation,
+			"attempt":   attempt,
+			"attempts":  policy.Attempts,
+			"backoff":   backoff.String(),
+			"reason":    reason,
+		}).Warn("DefaultChef " + operation + " failed with a transient error, retrying")
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package common
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RetryPolicy
+		retry  int
+		want   time.Duration
+	}{
+		{name: "first retry", policy: RetryPolicy{InitialBackoff: 10 * time.Second, MaxBackoff: 2 * time.Minute, Multiplier: 2}, retry: 1, want: 10 * time.Second},
+		{name: "grows", policy: RetryPolicy{InitialBackoff: 10 * time.Second, MaxBackoff: 2 * time.Minute, Multiplier: 2}, retry: 3, want: 40 * time.Second},
+		{name: "capped", policy: RetryPolicy{InitialBackoff: 10 * time.Second, MaxBackoff: 2 * time.Minute, Multiplier: 2}, retry: 5, want: 2 * time.Minute},
+		{name: "constant", policy: RetryPolicy{InitialBackoff: 5 * time.Second, MaxBackoff: time.Minute, Multiplier: 1}, retry: 10, want: 5 * time.Second},
+		{name: "no maximum", policy: RetryPolicy{InitialBackoff: 10 * time.Second, Multiplier: 2}, retry: 3, want: 40 * time.Second},
+		{name: "no maximum capped by default", policy: RetryPolicy{InitialBackoff: 10 * time.Second, Multiplier: 2}, retry: 100, want: defaultMaxBackoff},
+		{name: "many retries", policy: RetryPolicy{InitialBackoff: 10 * time.Second, MaxBackoff: time.Hour, Multiplier: 10}, retry: 1000, want: time.Hour},
+		{name: "initial above maximum", policy: RetryPolicy{InitialBackoff: time.Hour, MaxBackoff: time.Minute, Multiplier: 2}, retry: 1, want: time.Minute},
+		{name: "fractional multiplier", policy: RetryPolicy{InitialBackoff: 10 * time.Second, MaxBackoff: time.Minute, Multiplier: 1.5}, retry: 3, want: 22500 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.backoff(tt.retry); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGetRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     RetryPolicyConfig
+		want    RetryPolicy
+		wantErr bool
+	}{
+		{name: "defaults", want: RetryPolicy{Attempts: 1, InitialBackoff: 10 * time.Second, MaxBackoff: 2 * time.Minute, Multiplier: 2}},
+		{
+			name: "configured",
+			cfg:  RetryPolicyConfig{Attempts: 3, InitialBackoff: "1s", MaxBackoff: "30s", Multiplier: 3},
+			want: RetryPolicy{Attempts: 3, InitialBackoff: time.Second, MaxBackoff: 30 * time.Second, Multiplier: 3},
+		},
+		{name: "negative attempts", cfg: RetryPolicyConfig{Attempts: -1}, wantErr: true},
+		{name: "shrinking multiplier", cfg: RetryPolicyConfig{Multiplier: 0.5}, wantErr: true},
+		{name: "invalid backoff", cfg: RetryPolicyConfig{InitialBackoff: "soon"}, wantErr: true},
+		{name: "negative backoff", cfg: RetryPolicyConfig{MaxBackoff: "-1s"}, wantErr: true},
+		{name: "invalid matcher", cfg: RetryPolicyConfig{Matchers: []string{"("}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GetRetryPolicy(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected an error: %v, got %v", tt.wantErr, err)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if got.Attempts != tt.want.Attempts || got.InitialBackoff != tt.want.InitialBackoff ||
+				got.MaxBackoff != tt.want.MaxBackoff || got.Multiplier != tt.want.Multiplier {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+
+			if len(got.Matchers) != len(defaultTransientErrorMatchers)+len(tt.cfg.Matchers) {
+				t.Errorf("expected the default matchers and the configured ones, got %d", len(got.Matchers))
+			}
+		})
+	}
+}
+
+func TestRetryPolicyTransient(t *testing.T) {
+	policy, err := GetRetryPolicy(RetryPolicyConfig{Matchers: []string{`LimitExceededException`}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		err  string
+		want string
+	}{
+		{err: "creating bucket: Throttling: Rate exceeded", want: "Throttl"},
+		{err: "creating function: InvalidParameterValueException: The role defined for the function cannot be assumed by Lambda.", want: "cannot be assumed by Lambda"},
+		{err: "creating queue: LimitExceededException: too many queues", want: "LimitExceededException"},
+		{err: "creating bucket: BucketAlreadyExists"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.err, func(t *testing.T) {
+			reason, ok := policy.transient(errors.New(tt.err))
+			if ok != (tt.want != "") || reason != tt.want {
+				t.Errorf("expected %q, got %q, %v", tt.want, reason, ok)
+			}
+		})
+	}
+}
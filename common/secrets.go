@@ -0,0 +1,62 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+)
+
+type SecretsProvider interface {
+	// Returns the Pulumi secrets provider string, either "passphrase" or a KMS URL like "awskms://...".
+	URL() string
+
+	// Returns the environment variables the Pulumi engine needs to use this secrets provider.
+	Env() (map[string]string, error)
+}
+
+// SecretsConfig is the "secrets" section of rapid.yaml.
+type SecretsConfig struct {
+	// Either "passphrase" (the default), or an "awskms://" or "hashivault://" URL.
+	Provider string `mapstructure:"provider" yaml:"provider"`
+	// Read the passphrase from this file.
+	PassphraseFile string `mapstructure:"passphraseFile" yaml:"passphraseFile"`
+	// Read the passphrase from this environment variable.
+	PassphraseEnv string `mapstructure:"passphraseEnv" yaml:"passphraseEnv"`
+	// Read the passphrase from the standard output of this command.
+	PassphraseCommand string `mapstructure:"passphraseCommand" yaml:"passphraseCommand"`
+}
+
+// Returns the secrets provider described by the configuration.
+func GetSecretsProvider(cfg SecretsConfig) (SecretsProvider, error) {
+	provider := strings.TrimSpace(cfg.Provider)
+
+	if provider == "" || provider == passphraseProvider {
+		sources := 0
+
+		for _, source := range []string{cfg.PassphraseFile, cfg.PassphraseEnv, cfg.PassphraseCommand} {
+			if source != "" {
+				sources++
+			}
+		}
+
+		if sources > 1 {
+			return nil, fmt.Errorf("secrets: only one of passphraseFile, passphraseEnv and passphraseCommand may be set")
+		}
+
+		switch {
+		case cfg.PassphraseFile != "":
+			return GetPassphraseFileSecretsProvider(cfg.PassphraseFile), nil
+		case cfg.PassphraseCommand != "":
+			return GetPassphraseCommandSecretsProvider(cfg.PassphraseCommand), nil
+		case cfg.PassphraseEnv != "":
+			return GetPassphraseEnvSecretsProvider(cfg.PassphraseEnv), nil
+		}
+
+		return GetPassphraseEnvSecretsProvider(passphraseEnvVar), nil
+	}
+
+	if cfg.PassphraseFile != "" || cfg.PassphraseEnv != "" || cfg.PassphraseCommand != "" {
+		return nil, fmt.Errorf("secrets: passphrase settings cannot be combined with provider %q", provider)
+	}
+
+	return GetKMSSecretsProvider(provider)
+}
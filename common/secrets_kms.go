@@ -0,0 +1,37 @@
+package common
+
+import (
+	"fmt"
+	"net/url"
+)
+
+type kmsSecretsProvider struct {
+	url string // The KMS key URL, e.g. "awskms://alias/my-key?region=eu-central-1".
+}
+
+// Secrets provider backed by a key management service. Credentials for the service are taken from the
+// well-known environment of the respective cloud, e.g. AWS_PROFILE or VAULT_TOKEN.
+func GetKMSSecretsProvider(keyURL string) (SecretsProvider, error) {
+	parsed, err := url.Parse(keyURL)
+	if err != nil {
+		return nil, fmt.Errorf("secrets: invalid provider URL %q: %w", keyURL, err)
+	}
+
+	switch parsed.Scheme {
+	case "awskms", "hashivault":
+	default:
+		return nil, fmt.Errorf("secrets: unsupported provider %q, expected passphrase, awskms:// or hashivault://", keyURL)
+	}
+
+	return &kmsSecretsProvider{url: keyURL}, nil
+}
+
+// URL implements SecretsProvider.
+func (sp *kmsSecretsProvider) URL() string {
+	return sp.url
+}
+
+// Env implements SecretsProvider.
+func (sp *kmsSecretsProvider) Env() (map[string]string, error) {
+	return map[string]string{}, nil
+}
@@ -0,0 +1,102 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/apex/log"
+)
+
+const (
+	passphraseProvider = "passphrase"
+	passphraseEnvVar   = "PULUMI_CONFIG_PASSPHRASE"
+)
+
+type passphraseSecretsProvider struct {
+	source     string                 // A human readable description of where the passphrase comes from.
+	passphrase func() (string, error) // Reads the passphrase, on every call.
+}
+
+// Secrets provider that reads the passphrase from a file.
+func GetPassphraseFileSecretsProvider(file string) SecretsProvider {
+	return &passphraseSecretsProvider{
+		source: "file " + file,
+		passphrase: func() (string, error) {
+			content, err := os.ReadFile(file) // #nosec G304 -- the file is chosen by the user
+			if err != nil {
+				return "", err
+			}
+
+			return string(content), nil
+		},
+	}
+}
+
+// Secrets provider that reads the passphrase from an environment variable.
+func GetPassphraseEnvSecretsProvider(name string) SecretsProvider {
+	return &passphraseSecretsProvider{
+		source: "environment variable " + name,
+		passphrase: func() (string, error) {
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				return "", fmt.Errorf("environment variable %s is not set", name)
+			}
+
+			return value, nil
+		},
+	}
+}
+
+// Secrets provider that reads the passphrase from the standard output of a command.
+func GetPassphraseCommandSecretsProvider(command string) SecretsProvider {
+	return &passphraseSecretsProvider{
+		source: "command " + command,
+		passphrase: func() (string, error) {
+			args := strings.Fields(command)
+			if len(args) == 0 {
+				return "", fmt.Errorf("empty passphrase command")
+			}
+
+			out, err := exec.Command(args[0], args[1:]...).Output() // #nosec G204 -- the command is chosen by the user
+			if err != nil {
+				return "", err
+			}
+
+			return string(out), nil
+		},
+	}
+}
+
+// URL implements SecretsProvider.
+func (sp *passphraseSecretsProvider) URL() string {
+	return passphraseProvider
+}
+
+// Env implements SecretsProvider.
+func (sp *passphraseSecretsProvider) Env() (map[string]string, error) {
+	passphrase, err := sp.Passphrase()
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{passphraseEnvVar: passphrase}, nil
+}
+
+// Passphrase returns the passphrase, without any trailing line break.
+func (sp *passphraseSecretsProvider) Passphrase() (string, error) {
+	passphrase, err := sp.passphrase()
+	if err != nil {
+		log.WithField("source", sp.source).WithError(err).Error("PassphraseSecretsProvider failed to read passphrase")
+
+		return "", fmt.Errorf("reading passphrase from %s: %w", sp.source, err)
+	}
+
+	passphrase = strings.TrimRight(passphrase, "\r\n")
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase from %s is empty", sp.source)
+	}
+
+	return passphrase, nil
+}
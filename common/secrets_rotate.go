@@ -0,0 +1,227 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optlist"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	"gopkg.in/yaml.v3"
+)
+
+// The project used to list the stacks of all projects in a state store.
+const rotationProject = "cloudprism"
+
+// Changes the secrets provider of every stack in the state store to newProvider, re-encrypting the stack config
+// and state secrets, and verifies that the stack can be decrypted with the new provider afterwards.
+// Stacks already using the new provider are skipped, so an interrupted rotation is resumed by running it again.
+// The state store itself keeps its old secrets provider, it must be configured with newProvider afterwards.
+// Rotation runs in temporary workspaces and cannot re-encrypt the stack config files of the project directory, so
+// it refuses to start if any of them holds encrypted values.
+func RotateSecretsProvider(store StateStore, newProvider SecretsProvider, projectDir string) error {
+	if err := checkReadOnly(store, "Secrets provider rotation"); err != nil {
+		return err
+	}
+
+	if err := checkStackConfigFiles(projectDir); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	stateURI, err := store.StoreOpen()
+	if err != nil {
+		return err
+	}
+
+	oldOptions, err := GetWorkspaceOptions(store, stateURI)
+	if err != nil {
+		return fmt.Errorf("current secrets provider: %w", err)
+	}
+
+	newOptions, err := getWorkspaceOptions(newProvider, stateURI)
+	if err != nil {
+		return fmt.Errorf("new secrets provider: %w", err)
+	}
+
+	newPassphrase := ""
+
+	if pp, ok := newProvider.(*passphraseSecretsProvider); ok {
+		if newPassphrase, err = pp.Passphrase(); err != nil {
+			return err
+		}
+	}
+
+	listWorkspace, err := getProjectWorkspace(ctx, rotationProject, oldOptions...)
+	if err != nil {
+		return err
+	}
+
+	stacks, err := listWorkspace.ListStacks(ctx, optlist.All())
+	if err != nil {
+		log.WithField("stateUri", stateURI).WithError(err).Error("RotateSecretsProvider failed to list stacks")
+
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"stateUri": stateURI,
+		"stacks":   len(stacks),
+		"provider": newProvider.URL(),
+	}).Info("Rotating secrets provider")
+
+	for _, summary := range stacks {
+		project, stack := splitStackName(summary.Name, rotationProject)
+		fields := log.Fields{"project": project, "stack": stack}
+
+		oldWorkspace, err := getProjectWorkspace(ctx, project, oldOptions...)
+		if err != nil {
+			return err
+		}
+
+		newWorkspace, err := getProjectWorkspace(ctx, project, newOptions...)
+		if err != nil {
+			return err
+		}
+
+		if verifySecretsProvider(ctx, newWorkspace, stack, newProvider) == nil {
+			log.WithFields(fields).Info("Stack already uses the new secrets provider or has no secrets, skipping")
+
+			continue
+		}
+
+		opts := &auto.ChangeSecretsProviderOptions{}
+		if newPassphrase != "" {
+			opts.NewPassphrase = &newPassphrase
+		}
+
+		log.WithFields(fields).Info("Changing secrets provider")
+
+		if err := oldWorkspace.ChangeStackSecretsProvider(ctx, stack, newProvider.URL(), opts); err != nil {
+			log.WithFields(fields).WithError(err).Error("RotateSecretsProvider failed to change secrets provider")
+
+			return fmt.Errorf("stack %s/%s: %w", project, stack, err)
+		}
+
+		if err := verifySecretsProvider(ctx, newWorkspace, stack, newProvider); err != nil {
+			log.WithFields(fields).WithError(err).Error("RotateSecretsProvider failed to verify new secrets provider")
+
+			return fmt.Errorf("stack %s/%s: verifying new secrets provider: %w", project, stack, err)
+		}
+
+		log.WithFields(fields).Info("Secrets provider changed and verified")
+	}
+
+	return nil
+}
+
+// Checks that the stack state is encrypted with the given secrets provider and can be decrypted with it.
+func verifySecretsProvider(ctx context.Context, ws auto.Workspace, stack string, provider SecretsProvider) error {
+	exported, err := ws.ExportStack(ctx, stack)
+	if err != nil {
+		return err
+	}
+
+	deployment := apitype.DeploymentV3{}
+	if len(exported.Deployment) > 0 {
+		if err := json.Unmarshal(exported.Deployment, &deployment); err != nil {
+			return err
+		}
+	}
+
+	// Stacks without any deployment have no secrets to rotate.
+	if deployment.SecretsProviders == nil {
+		return nil
+	}
+
+	switch deployment.SecretsProviders.Type {
+	case passphraseProvider:
+		if provider.URL() != passphraseProvider {
+			return fmt.Errorf("stack is encrypted with a passphrase")
+		}
+	case "cloud":
+		state := struct {
+			URL string `json:"url"`
+		}{}
+
+		if err := json.Unmarshal(deployment.SecretsProviders.State, &state); err != nil {
+			return err
+		}
+
+		if state.URL != provider.URL() {
+			return fmt.Errorf("stack is encrypted with %s", state.URL)
+		}
+	default:
+		return fmt.Errorf("stack is encrypted with secrets provider type %s", deployment.SecretsProviders.Type)
+	}
+
+	// Decrypting all outputs proves the provider actually works for this stack.
+	if _, err := ws.StackOutputs(ctx, stack); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Returns an error listing the stack config files, Pulumi.<stack>.yaml, of a project directory holding values
+// encrypted with the current secrets provider.
+func checkStackConfigFiles(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "Pulumi.*.yaml"))
+	if err != nil {
+		return err
+	}
+
+	encrypted := []string{}
+
+	for _, file := range files {
+		content, err := os.ReadFile(file) // #nosec G304 -- the files of the project directory
+		if err != nil {
+			return err
+		}
+
+		doc := yaml.Node{}
+		if err := yaml.Unmarshal(content, &doc); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+
+		if hasSecureValue(&doc) {
+			encrypted = append(encrypted, file)
+		}
+	}
+
+	if len(encrypted) == 0 {
+		return nil
+	}
+
+	sort.Strings(encrypted)
+
+	return fmt.Errorf("stack config files hold values encrypted with the current secrets provider, which rotation "+
+		"cannot re-encrypt; run \"pulumi stack change-secrets-provider\" for their stacks in %s first, or move the "+
+		"values out of them: %s", dir, strings.Join(encrypted, ", "))
+}
+
+// Returns true if a YAML node holds a value encrypted by Pulumi, i.e. a mapping with a "secure" key.
+func hasSecureValue(node *yaml.Node) bool {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == "secure" && node.Content[i+1].Kind == yaml.ScalarNode {
+				return true
+			}
+		}
+	}
+
+	for _, child := range node.Content {
+		if hasSecureValue(child) {
+			return true
+		}
+	}
+
+	return false
+}
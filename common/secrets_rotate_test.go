@@ -0,0 +1,62 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckStackConfigFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{name: "no stack config files"},
+		{
+			name:  "plain values only",
+			files: map[string]string{"Pulumi.dev.yaml": "config:\n  aws:region: eu-central-1\n"},
+		},
+		{
+			name: "encrypted value",
+			files: map[string]string{
+				"Pulumi.dev.yaml": "config:\n  aws:region: eu-central-1\n",
+				"Pulumi.prd.yaml": "config:\n  app:password:\n    secure: v1:abc\n",
+			},
+			wantErr: "Pulumi.prd.yaml",
+		},
+		{
+			name:    "encrypted value nested in an object",
+			files:   map[string]string{"Pulumi.int.yaml": "config:\n  app:db:\n    credentials:\n      password:\n        secure: v1:abc\n"},
+			wantErr: "Pulumi.int.yaml",
+		},
+		{
+			name:  "key named secure holding an object",
+			files: map[string]string{"Pulumi.dev.yaml": "config:\n  app:flags:\n    secure:\n      enabled: true\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := checkStackConfigFiles(dir)
+
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && err == nil:
+				t.Errorf("expected an error mentioning %s", tt.wantErr)
+			case tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr):
+				t.Errorf("error %q does not mention %s", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,5 +1,13 @@
 package common
 
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrReadOnly is returned for operations that would modify a read-only state store or stack.
+var ErrReadOnly = errors.New("read-only access")
+
 type StateStore interface {
 	// Creates and/or logs in to a state store and returns its URL string.
 	StoreOpen() (string, error)
@@ -9,4 +17,79 @@ type StateStore interface {
 
 	// Deletes the state store, including all data when the force parameter is true.
 	StoreDelete(force bool) error
+
+	// Returns the secrets provider used for all stacks in the state store.
+	SecretsProvider() SecretsProvider
+
+	// Returns true if the state store must not be modified, neither created nor deleted.
+	ReadOnly() bool
+}
+
+type StateStoreOption func(*stateStoreOptions)
+
+type stateStoreOptions struct {
+	secretsProvider SecretsProvider // The secrets provider for all stacks in the state store.
+	readOnly        bool            // Refuse all operations modifying the state store or its stacks.
+}
+
+// Use the given secrets provider instead of a passphrase from PULUMI_CONFIG_PASSPHRASE.
+func WithSecretsProvider(secretsProvider SecretsProvider) StateStoreOption {
+	return func(o *stateStoreOptions) {
+		o.secretsProvider = secretsProvider
+	}
+}
+
+// Open the state store for reading only, e.g. for auditors and dashboards.
+// A state store that does not exist yet is not created.
+func WithReadOnly() StateStoreOption {
+	return func(o *stateStoreOptions) {
+		o.readOnly = true
+	}
+}
+
+// Returns an ErrReadOnly error for the operation if the state store is read-only.
+func checkReadOnly(store StateStore, operation string) error {
+	if store.ReadOnly() {
+		return fmt.Errorf("%s refused: %w", operation, ErrReadOnly)
+	}
+
+	return nil
+}
+
+func getStateStoreOptions(opts []StateStoreOption) stateStoreOptions {
+	options := stateStoreOptions{}
+
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if options.secretsProvider == nil {
+		options.secretsProvider = GetPassphraseEnvSecretsProvider(passphraseEnvVar)
+	}
+
+	return options
+}
+
+// StateStoreConfig is the "stateStore" section of rapid.yaml.
+type StateStoreConfig struct {
+	// Either "local" (the default) or "awss3".
+	Type string `mapstructure:"type" yaml:"type"`
+	// The base path of a local state store folder.
+	Path string `mapstructure:"path" yaml:"path"`
+	// The name of a local state store folder.
+	Name string `mapstructure:"name" yaml:"name"`
+	// The tags to apply to a newly created AWS S3 state bucket.
+	Tags map[string]string `mapstructure:"tags" yaml:"tags"`
+}
+
+// Returns the state store described by the configuration for an application environment.
+func GetStateStore(cfg StateStoreConfig, app Application, env ApplicationEnvironment, opts ...StateStoreOption) (StateStore, error) {
+	switch cfg.Type {
+	case "", "local":
+		return GetDefaultStateStore(cfg.Path, cfg.Name, opts...)
+	case "awss3":
+		return GetAWSS3StateStore(string(StateStoreName(app, env)), cfg.Tags, opts...)
+	}
+
+	return nil, fmt.Errorf("unsupported state store type %q, expected local or awss3", cfg.Type)
 }
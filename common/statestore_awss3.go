@@ -27,10 +27,11 @@ type awsS3StateStore struct {
 	awsCredentials awsS3StateStoreCredentials // The AWS credentials to use for the state store creation.
 	awsAPIClient   *s3.Client                 // The AWS API client to use for the state store creation.
 	awsAPIContext  context.Context            // The AWS API context to use for the state store creation.
+	options        stateStoreOptions          // The options the state store was created with.
 }
 
 // Create state store with the well-known credentials from the environment.
-func GetAWSS3StateStore(baseName string, bucketTags map[string]string) (StateStore, error) {
+func GetAWSS3StateStore(baseName string, bucketTags map[string]string, opts ...StateStoreOption) (StateStore, error) {
 	awsCredentials := awsS3StateStoreCredentials{
 		awsAccesskey:    os.Getenv("AWS_ACCESS_KEY_ID"),
 		awsSecretkey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
@@ -58,6 +59,7 @@ func GetAWSS3StateStore(baseName string, bucketTags map[string]string) (StateSto
 		awsCredentials: awsCredentials,
 		awsAPIClient:   awsAPIClient,
 		awsAPIContext:  awsAPIContext,
+		options:        getStateStoreOptions(opts),
 	}, nil
 }
 
@@ -75,6 +77,10 @@ func (ss *awsS3StateStore) StoreOpen() (string, error) {
 
 	// If the bucket does not exist, create it
 	if !exists {
+		if err := checkReadOnly(ss, "AWSS3StateStore creating bucket "+ss.BucketName()); err != nil {
+			return "", err
+		}
+
 		if err := ss.createBucket(); err != nil {
 			return "", err
 		}
@@ -89,12 +95,6 @@ func (ss *awsS3StateStore) StoreOpen() (string, error) {
 	// Bucket exists, or was created successfully, so log in to it
 	stateURI := "s3://" + ss.BucketName()
 
-	// TODO: get this from the config
-	err = os.Setenv("PULUMI_CONFIG_PASSPHRASE", "we need a persistent token for the project here")
-	if err != nil {
-		return "", err
-	}
-
 	// validate stateUri
 	_, err = url.ParseRequestURI(stateURI)
 	if err != nil {
@@ -135,6 +135,10 @@ func (ss *awsS3StateStore) StoreClose() error {
 
 // Deletes the state store, including all data when the force parameter is true.
 func (ss *awsS3StateStore) StoreDelete(force bool) error {
+	if err := checkReadOnly(ss, "AWSS3StateStore deleting bucket "+ss.BucketName()); err != nil {
+		return err
+	}
+
 	err := ss.StoreClose()
 	if err != nil {
 		return err
@@ -148,6 +152,16 @@ func (ss *awsS3StateStore) StoreDelete(force bool) error {
 	return nil
 }
 
+// Returns the secrets provider used for all stacks in the state store.
+func (ss *awsS3StateStore) SecretsProvider() SecretsProvider {
+	return ss.options.secretsProvider
+}
+
+// Returns true if the state store must not be modified, neither created nor deleted.
+func (ss *awsS3StateStore) ReadOnly() bool {
+	return ss.options.readOnly
+}
+
 func (ss *awsS3StateStore) deleteBucket(force bool) error {
 	log.WithFields(log.Fields{
 		"bucket": ss.BucketName(),
@@ -277,6 +291,10 @@ func (ss *awsS3StateStore) deleteBucket(force bool) error {
 }
 
 func (ss *awsS3StateStore) createBucket() error {
+	if err := checkReadOnly(ss, "AWSS3StateStore creating bucket "+ss.BucketName()); err != nil {
+		return err
+	}
+
 	log.WithFields(log.Fields{
 		"bucket": ss.BucketName(),
 		"region": ss.bucketRegion,
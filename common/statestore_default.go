@@ -14,9 +14,11 @@ type DefaultStateStore struct {
 	name string
 	// The base path to the directory to use for the state store folder.
 	path string
+	// The options the state store was created with.
+	options stateStoreOptions
 }
 
-func GetDefaultStateStore(path, name string) (StateStore, error) {
+func GetDefaultStateStore(path, name string, opts ...StateStoreOption) (StateStore, error) {
 	log.WithFields(log.Fields{
 		"path": path,
 		"name": name,
@@ -31,8 +33,9 @@ func GetDefaultStateStore(path, name string) (StateStore, error) {
 	}
 
 	return &DefaultStateStore{
-		name: name,
-		path: path,
+		name:    name,
+		path:    path,
+		options: getStateStoreOptions(opts),
 	}, nil
 }
 
@@ -46,17 +49,14 @@ func (ss *DefaultStateStore) StoreOpen() (string, error) {
 
 	log.WithField("stateUri", stateURI).Debug("DefaultStateStore.StoreOpen()")
 
-	err := os.MkdirAll(statePath, os.ModePerm)
-	if err != nil {
-		log.WithField("statePath", statePath).WithError(err).Error("DefaultStateStore create directory failed")
-
-		return "", err
-	}
+	if ss.ReadOnly() {
+		if _, err := os.Stat(statePath); err != nil {
+			log.WithField("statePath", statePath).WithError(err).Error("DefaultStateStore read-only directory not accessible")
 
-	// TODO: get this from the config
-	err = os.Setenv("PULUMI_CONFIG_PASSPHRASE", "we need a persistent token for the project here")
-	if err != nil {
-		log.WithField("statePath", statePath).WithError(err).Error("DefaultStateStore set env failed")
+			return "", err
+		}
+	} else if err := os.MkdirAll(statePath, os.ModePerm); err != nil {
+		log.WithField("statePath", statePath).WithError(err).Error("DefaultStateStore create directory failed")
 
 		return "", err
 	}
@@ -64,9 +64,8 @@ func (ss *DefaultStateStore) StoreOpen() (string, error) {
 	cmd := exec.Command("pulumi", "login", path.Clean(stateURI)) // #nosec G204
 	pwd, _ := os.Getwd()
 	cmd.Dir = pwd
-	_, err = cmd.Output()
 
-	if err != nil {
+	if _, err := cmd.Output(); err != nil {
 		log.WithField("stateUri", stateURI).WithError(err).Error("DefaultStateStore login failed")
 
 		return "", err
@@ -101,6 +100,10 @@ func (ss *DefaultStateStore) StoreDelete(force bool) error {
 
 	log.WithField("statePath", statePath).Debug("DefaultStateStore.StoreDelete()")
 
+	if err := checkReadOnly(ss, "DefaultStateStore deleting "+statePath); err != nil {
+		return err
+	}
+
 	if force {
 		err := os.RemoveAll(statePath)
 		if err != nil {
@@ -119,3 +122,13 @@ func (ss *DefaultStateStore) StoreDelete(force bool) error {
 
 	return nil
 }
+
+// Returns the secrets provider used for all stacks in the state store.
+func (ss *DefaultStateStore) SecretsProvider() SecretsProvider {
+	return ss.options.secretsProvider
+}
+
+// Returns true if the state store must not be modified, neither created nor deleted.
+func (ss *DefaultStateStore) ReadOnly() bool {
+	return ss.options.readOnly
+}
@@ -0,0 +1,163 @@
+package common
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/apex/log"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// The keys of the standard tags applied to all taggable resources.
+const (
+	TagApplication       = "Application"
+	TagEnvironment       = "Environment"
+	TagOwner             = "Owner"
+	TagCostCenter        = "CostCenter"
+	TagGitRevision       = "GitRevision"
+	TagCloudPrismVersion = "CloudPrismVersion"
+)
+
+// TagsConfig is the "tags" section of rapid.yaml.
+type TagsConfig struct {
+	// The team or person owning the application.
+	Owner string `mapstructure:"owner" yaml:"owner"`
+	// The cost center the resources are billed to.
+	CostCenter string `mapstructure:"costCenter" yaml:"costCenter"`
+	// Additional tags applied to all taggable resources.
+	Extra map[string]string `mapstructure:"extra" yaml:"extra"`
+}
+
+// TaggingIngredient is implemented by ingredients overriding the standard tags of the resources they create.
+// An empty value removes a standard tag, e.g. for resources limiting the number of tags.
+type TaggingIngredient interface {
+	Tags() map[string]string
+}
+
+// Returns the standard tags of an application environment. The target is the deployment target name of the
+// deployed sources, see GetDeploymentTargetName, the version the one of CloudPrism itself.
+func GetStandardTags(cfg TagsConfig, app Application, env ApplicationEnvironment, target, version string) map[string]string {
+	tags := make(map[string]string, len(cfg.Extra)+6)
+
+	for key, value := range cfg.Extra {
+		tags[key] = value
+	}
+
+	tags[TagApplication] = app.ID()
+	tags[TagEnvironment] = env.ID()
+	tags[TagGitRevision] = target
+	tags[TagCloudPrismVersion] = version
+
+	if cfg.Owner != "" {
+		tags[TagOwner] = cfg.Owner
+	} else {
+		log.WithField("tag", TagOwner).Warn("No owner configured in the tags section, resources will not carry the tag")
+	}
+
+	if cfg.CostCenter != "" {
+		tags[TagCostCenter] = cfg.CostCenter
+	} else {
+		log.WithField("tag", TagCostCenter).Warn("No cost center configured in the tags section, resources will not carry the tag")
+	}
+
+	return tags
+}
+
+// Merges the tags into all taggable resources, the ones whose arguments have a Tags field of type
+// pulumi.StringMapInput. Tags set on a resource itself are overridden by the standard tags,
+// which in turn are overridden by the tags of a TaggingIngredient.
+func WithStandardTags(tags map[string]string) ProgramOption {
+	return func(opts *programOptions) {
+		opts.tags = tags
+	}
+}
+
+// nolint: gochecknoglobals
+var stringMapInputType = reflect.TypeOf((*pulumi.StringMapInput)(nil)).Elem()
+
+// A stack transformation merging the standard tags, and the ones of the ingredient being upserted, into the
+// arguments of a taggable resource. Resources created asynchronously, e.g. in ApplyT, get the standard tags only.
+// Imported resources are left untagged, their inputs must match the cloud resource; the next update tags them.
+func (ps *programState) tagResource(args *pulumi.ResourceTransformationArgs) *pulumi.ResourceTransformationResult {
+	if importedResource(args) {
+		return nil
+	}
+
+	tags := make(map[string]string, len(ps.options.tags))
+	for key, value := range ps.options.tags {
+		tags[key] = value
+	}
+
+	if ps.ingredient != nil {
+		if tagging, ok := ingredientAs[TaggingIngredient](ps.ingredient); ok {
+			for key, value := range tagging.Tags() {
+				tags[key] = value
+			}
+		}
+	}
+
+	if len(tags) == 0 {
+		return nil
+	}
+
+	props := reflect.ValueOf(args.Props)
+	if !props.IsValid() || props.Kind() != reflect.Ptr || props.IsNil() || props.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+
+	// Work on a copy, the arguments may be shared by several resources.
+	copied := reflect.New(props.Elem().Type())
+	copied.Elem().Set(props.Elem())
+
+	field := copied.Elem().FieldByName("Tags")
+	if !field.IsValid() || !field.CanSet() || field.Type() != stringMapInputType {
+		return nil
+	}
+
+	log.WithFields(log.Fields{
+		"resource": args.Name,
+		"type":     args.Type,
+		"tags":     sortedKeys(tags),
+	}).Debug("Tagging resource")
+
+	merge := func(own map[string]string) map[string]string {
+		merged := make(map[string]string, len(own)+len(tags))
+		for key, value := range own {
+			merged[key] = value
+		}
+
+		for key, value := range tags {
+			if value == "" {
+				delete(merged, key)
+			} else {
+				merged[key] = value
+			}
+		}
+
+		return merged
+	}
+
+	if field.IsNil() {
+		field.Set(reflect.ValueOf(pulumi.ToStringMap(merge(nil))))
+	} else {
+		own := field.Interface().(pulumi.StringMapInput)
+		field.Set(reflect.ValueOf(own.ToStringMapOutput().ApplyT(merge).(pulumi.StringMapOutput)))
+	}
+
+	return &pulumi.ResourceTransformationResult{
+		Props: copied.Interface().(pulumi.Input),
+		Opts:  args.Opts,
+	}
+}
+
+// Returns the keys of a map, sorted.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
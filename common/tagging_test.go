@@ -0,0 +1,155 @@
+package common_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"sourcesign.de/cloudprism/common"
+	"sourcesign.de/cloudprism/common/recipetest"
+)
+
+// taggedBucketIngredient registers a bucket with its own tags, nil for none, and overrides the standard tags.
+type taggedBucketIngredient struct {
+	own       map[string]string
+	overrides map[string]string
+}
+
+func (ti *taggedBucketIngredient) Upsert(ctx *pulumi.Context) error {
+	args := &bucketArgs{}
+	if ti.own != nil {
+		args.Tags = pulumi.ToStringMap(ti.own)
+	}
+
+	return ctx.RegisterResource(bucketType, "bucket", args, &pulumi.CustomResourceState{})
+}
+
+func (ti *taggedBucketIngredient) Result() interface{} {
+	return nil
+}
+
+func (ti *taggedBucketIngredient) Tags() map[string]string {
+	return ti.overrides
+}
+
+func TestGetStandardTags(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  common.TagsConfig
+		want map[string]string
+	}{
+		{
+			name: "configured",
+			cfg:  common.TagsConfig{Owner: "team-a", CostCenter: "4711", Extra: map[string]string{"Team": "a"}},
+			want: map[string]string{
+				"Application": "shop", "Environment": "dev", "GitRevision": "abc123", "CloudPrismVersion": "1.2.0",
+				"Owner": "team-a", "CostCenter": "4711", "Team": "a",
+			},
+		},
+		{
+			name: "extra tags overridden by standard tags",
+			cfg:  common.TagsConfig{Extra: map[string]string{"Application": "other", "Owner": "team-b"}},
+			want: map[string]string{
+				"Application": "shop", "Environment": "dev", "GitRevision": "abc123", "CloudPrismVersion": "1.2.0",
+				"Owner": "team-b",
+			},
+		},
+		{
+			name: "extra tags overridden by configured owner",
+			cfg:  common.TagsConfig{Owner: "team-a", Extra: map[string]string{"Owner": "team-b"}},
+			want: map[string]string{
+				"Application": "shop", "Environment": "dev", "GitRevision": "abc123", "CloudPrismVersion": "1.2.0",
+				"Owner": "team-a",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := common.GetStandardTags(tt.cfg, "shop", common.AppEnvDevelopment, "abc123", "1.2.0")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTagResource(t *testing.T) {
+	standard := map[string]string{"Environment": "dev", "Owner": "team-a"}
+
+	tests := []struct {
+		name       string
+		ingredient *taggedBucketIngredient
+		want       map[string]interface{}
+	}{
+		{
+			name:       "untagged resource",
+			ingredient: &taggedBucketIngredient{},
+			want:       map[string]interface{}{"Environment": "dev", "Owner": "team-a"},
+		},
+		{
+			name:       "own tags kept",
+			ingredient: &taggedBucketIngredient{own: map[string]string{"Name": "logs"}},
+			want:       map[string]interface{}{"Name": "logs", "Environment": "dev", "Owner": "team-a"},
+		},
+		{
+			name:       "own tags overridden by standard tags",
+			ingredient: &taggedBucketIngredient{own: map[string]string{"Owner": "team-b"}},
+			want:       map[string]interface{}{"Environment": "dev", "Owner": "team-a"},
+		},
+		{
+			name: "standard tags overridden by the ingredient",
+			ingredient: &taggedBucketIngredient{
+				own:       map[string]string{"Owner": "team-b"},
+				overrides: map[string]string{"Owner": "team-c", "Backup": "daily"},
+			},
+			want: map[string]interface{}{"Environment": "dev", "Owner": "team-c", "Backup": "daily"},
+		},
+		{
+			name: "standard and own tags removed by the ingredient",
+			ingredient: &taggedBucketIngredient{
+				own:       map[string]string{"Name": "logs"},
+				overrides: map[string]string{"Owner": "", "Name": ""},
+			},
+			want: map[string]interface{}{"Environment": "dev"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recipe := common.GetDefaultRecipe("storage")
+			recipe.Append(tt.ingredient)
+
+			result, err := recipetest.Run([]common.Recipe{recipe}, recipetest.WithProgramOptions(common.WithStandardTags(standard)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result.AssertProperty(t, bucketType, "tags", tt.want)
+		})
+	}
+}
+
+func TestTagResourcePerIngredient(t *testing.T) {
+	recipe := common.GetDefaultRecipe("storage")
+	recipe.Append(
+		&taggedBucketIngredient{overrides: map[string]string{"Owner": ""}},
+		&bucketsIngredient{names: []string{"other"}},
+	)
+
+	result, err := recipetest.Run([]common.Recipe{recipe},
+		recipetest.WithProgramOptions(common.WithStandardTags(map[string]string{"Owner": "team-a"})))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bucket := result.AssertNamedResource(t, bucketType, "bucket")
+	if bucket.Inputs["tags"].ObjectValue().HasValue("Owner") {
+		t.Errorf("expected the Owner tag to be removed from bucket, got %v", bucket.Inputs["tags"])
+	}
+
+	other := result.AssertNamedResource(t, bucketType, "other")
+	if got := other.Inputs["tags"].ObjectValue()["Owner"]; !got.IsString() || got.StringValue() != "team-a" {
+		t.Errorf("expected the Owner tag team-a on other, got %v", other.Inputs["tags"])
+	}
+}
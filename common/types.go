@@ -1,8 +1,19 @@
 package common
 
 import (
+	"fmt"
 	"regexp"
 	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// The types can be used as command-line flags.
+// nolint: gochecknoglobals
+var (
+	_ pflag.Value = (*ApplicationEnvironment)(nil)
+	_ pflag.Value = (*EnvironmentFlag)(nil)
+	_ pflag.Value = (*Application)(nil)
 )
 
 type ApplicationEnvironment uint
@@ -15,37 +26,158 @@ const (
 )
 
 func (s ApplicationEnvironment) Name() string {
-	switch s {
-	case AppEnvSandbox:
-		return "Sandbox"
-	case AppEnvDevelopment:
-		return "Development"
-	case AppEnvIntegration:
-		return "Integration"
-	case AppEnvProduction:
-		return "Production"
+	if def, ok := s.definition(); ok {
+		return def.name
 	}
 
 	return "Unknown"
 }
 
 func (s ApplicationEnvironment) ID() string {
-	switch s {
-	case AppEnvSandbox:
-		return "sbx"
-	case AppEnvDevelopment:
-		return "dev"
-	case AppEnvIntegration:
-		return "int"
-	case AppEnvProduction:
-		return "prd"
+	if def, ok := s.definition(); ok {
+		return def.id
 	}
 
 	return "etc"
 }
 
+// Returns the application environment with a short ID or name, e.g. "prd" or "Production", from the environment
+// catalogue. Case and surrounding spaces are ignored.
+func ParseApplicationEnvironment(text string) (ApplicationEnvironment, error) {
+	text = strings.TrimSpace(text)
+	known := []string{}
+
+	for _, env := range Environments() {
+		if strings.EqualFold(env.ID(), text) || strings.EqualFold(env.Name(), text) {
+			return env, nil
+		}
+
+		known = append(known, env.ID()+" ("+env.Name()+")")
+	}
+
+	return 0, fmt.Errorf("unknown environment %q, expected one of %s", text, strings.Join(known, ", "))
+}
+
+// Returns the short ID, e.g. "prd".
+func (s ApplicationEnvironment) String() string {
+	return s.ID()
+}
+
+// Set implements pflag.Value. The environment is resolved right away, use EnvironmentFlag for flags parsed before
+// the config file configures the environment catalogue.
+func (s *ApplicationEnvironment) Set(text string) error {
+	return s.UnmarshalText([]byte(text))
+}
+
+// Type implements pflag.Value.
+func (s *ApplicationEnvironment) Type() string {
+	return "environment"
+}
+
+// MarshalText implements encoding.TextMarshaler, used for JSON and YAML as well. Environments are written as IDs.
+func (s ApplicationEnvironment) MarshalText() ([]byte, error) {
+	if _, ok := s.definition(); !ok {
+		return nil, fmt.Errorf("unknown environment %d", uint(s))
+	}
+
+	return []byte(s.ID()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, used for JSON and YAML as well. Environments are read from
+// IDs or names.
+func (s *ApplicationEnvironment) UnmarshalText(text []byte) error {
+	env, err := ParseApplicationEnvironment(string(text))
+	if err != nil {
+		return err
+	}
+
+	*s = env
+
+	return nil
+}
+
+// EnvironmentFlag is a command-line flag taking an application environment by short ID or name. Flags are parsed
+// before the config file configures the environment catalogue, so the flag keeps the text until Environment
+// resolves it.
+type EnvironmentFlag struct {
+	text string // The short ID or name given.
+}
+
+// Returns a flag defaulting to an environment.
+func GetEnvironmentFlag(env ApplicationEnvironment) *EnvironmentFlag {
+	return &EnvironmentFlag{text: env.ID()}
+}
+
+// Returns the environment of the flag from the environment catalogue.
+func (f *EnvironmentFlag) Environment() (ApplicationEnvironment, error) {
+	return ParseApplicationEnvironment(f.text)
+}
+
+// String implements pflag.Value.
+func (f *EnvironmentFlag) String() string {
+	return f.text
+}
+
+// Set implements pflag.Value. Only the syntax is checked, the environment is resolved by Environment.
+func (f *EnvironmentFlag) Set(text string) error {
+	if strings.TrimSpace(text) == "" {
+		return fmt.Errorf("environment is empty")
+	}
+
+	f.text = text
+
+	return nil
+}
+
+// Type implements pflag.Value.
+func (f *EnvironmentFlag) Type() string {
+	return "environment"
+}
+
 type Application string
 
+// Returns the application with a name, which must contain letters or digits.
+func ParseApplication(text string) (Application, error) {
+	app := Application(strings.TrimSpace(text))
+	if !strings.ContainsAny(app.ID(), "abcdefghijklmnopqrstuvwxyz0123456789") {
+		return "", fmt.Errorf("invalid application name %q, it must contain letters or digits", text)
+	}
+
+	return app, nil
+}
+
+// Returns the name of the application.
+func (a Application) String() string {
+	return string(a)
+}
+
+// Set implements pflag.Value.
+func (a *Application) Set(text string) error {
+	return a.UnmarshalText([]byte(text))
+}
+
+// Type implements pflag.Value.
+func (a *Application) Type() string {
+	return "application"
+}
+
+// MarshalText implements encoding.TextMarshaler, used for JSON and YAML as well.
+func (a Application) MarshalText() ([]byte, error) {
+	return []byte(a), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, used for JSON and YAML as well.
+func (a *Application) UnmarshalText(text []byte) error {
+	app, err := ParseApplication(string(text))
+	if err != nil {
+		return err
+	}
+
+	*a = app
+
+	return nil
+}
+
 func (a Application) ID() string {
 	id := strings.ToLower(strings.ReplaceAll(string(a), " ", "-"))
 	reg := regexp.MustCompile("[^a-zA-Z0-9-]+")
@@ -58,3 +190,14 @@ type StateStoreNameType string
 func StateStoreName(app Application, env ApplicationEnvironment) StateStoreNameType {
 	return StateStoreNameType(app.ID() + "-" + env.ID())
 }
+
+// Returns the application environment with the given short ID, e.g. "prd", from the environment catalogue.
+func environmentByID(id string) (ApplicationEnvironment, bool) {
+	for _, env := range Environments() {
+		if env.ID() == id {
+			return env, true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,256 @@
+package common
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseApplicationEnvironment(t *testing.T) {
+	t.Cleanup(resetEnvironments(t))
+
+	if err := ConfigureEnvironments([]EnvironmentConfig{{ID: "qa", Name: "Quality Assurance"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	qa, _ := environmentByID("qa")
+
+	tests := []struct {
+		text    string
+		want    ApplicationEnvironment
+		wantErr bool
+	}{
+		{text: "prd", want: AppEnvProduction},
+		{text: "Production", want: AppEnvProduction},
+		{text: " DEV ", want: AppEnvDevelopment},
+		{text: "sandbox", want: AppEnvSandbox},
+		{text: "qa", want: qa},
+		{text: "quality assurance", want: qa},
+		{text: "etc", wantErr: true},
+		{text: "", wantErr: true},
+		{text: "staging", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := ParseApplicationEnvironment(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected an error: %v, got %v", tt.wantErr, err)
+			}
+
+			if err != nil {
+				if !strings.Contains(err.Error(), "qa (Quality Assurance)") {
+					t.Errorf("expected the error to list the known environments, got %v", err)
+				}
+
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestApplicationEnvironmentTextRoundTrip(t *testing.T) {
+	for _, env := range Environments() {
+		t.Run(env.ID(), func(t *testing.T) {
+			text, err := env.MarshalText()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(text) != env.ID() {
+				t.Errorf("expected %s, got %s", env.ID(), text)
+			}
+
+			var parsed ApplicationEnvironment
+			if err := parsed.UnmarshalText(text); err != nil {
+				t.Fatal(err)
+			}
+
+			if parsed != env {
+				t.Errorf("expected %s, got %s", env, parsed)
+			}
+		})
+	}
+
+	if _, err := ApplicationEnvironment(len(Environments())).MarshalText(); err == nil {
+		t.Error("expected an environment outside the catalogue to be refused")
+	}
+}
+
+func TestApplicationTextRoundTrip(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    Application
+		id      string
+		wantErr bool
+	}{
+		{text: "shop", want: "shop", id: "shop"},
+		{text: " My Shop ", want: "My Shop", id: "my-shop"},
+		{text: "Shop_2.0", want: "Shop_2.0", id: "shop20"},
+		{text: "", wantErr: true},
+		{text: "  ", wantErr: true},
+		{text: "!!!", wantErr: true},
+		{text: "---", wantErr: true},
+		{text: " - ", wantErr: true},
+		{text: "-a-", want: "-a-", id: "-a-"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			var app Application
+
+			err := app.UnmarshalText([]byte(tt.text))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected an error: %v, got %v", tt.wantErr, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if app != tt.want || app.ID() != tt.id {
+				t.Errorf("expected %q with ID %q, got %q with ID %q", tt.want, tt.id, app, app.ID())
+			}
+
+			text, err := app.MarshalText()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var parsed Application
+			if err := parsed.UnmarshalText(text); err != nil || parsed != app {
+				t.Errorf("expected %q, got %q, %v", app, parsed, err)
+			}
+		})
+	}
+}
+
+func TestTextMarshalingInDocuments(t *testing.T) {
+	type document struct {
+		Application Application            `json:"application" yaml:"application"`
+		Environment ApplicationEnvironment `json:"environment" yaml:"environment"`
+	}
+
+	want := document{Application: "My Shop", Environment: AppEnvIntegration}
+
+	t.Run("json", func(t *testing.T) {
+		data, err := json.Marshal(want)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != `{"application":"My Shop","environment":"int"}` {
+			t.Errorf("unexpected JSON %s", data)
+		}
+
+		var got document
+		if err := json.Unmarshal(data, &got); err != nil || got != want {
+			t.Errorf("expected %+v, got %+v, %v", want, got, err)
+		}
+	})
+
+	t.Run("yaml", func(t *testing.T) {
+		data, err := yaml.Marshal(want)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != "application: My Shop\nenvironment: int\n" {
+			t.Errorf("unexpected YAML %s", data)
+		}
+
+		var got document
+		if err := yaml.Unmarshal([]byte("application: My Shop\nenvironment: Integration\n"), &got); err != nil || got != want {
+			t.Errorf("expected %+v, got %+v, %v", want, got, err)
+		}
+	})
+}
+
+func TestEnvironmentFlagResolvesLate(t *testing.T) {
+	t.Cleanup(resetEnvironments(t))
+
+	flag := GetEnvironmentFlag(AppEnvDevelopment)
+	if flag.String() != "dev" {
+		t.Errorf("expected the default dev, got %s", flag)
+	}
+
+	if err := flag.Set(""); err == nil {
+		t.Error("expected an empty environment to be refused")
+	}
+
+	// Set before the catalogue knows the environment, like flags parsed before the config file is read.
+	if err := flag.Set("qa"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := flag.Environment(); err == nil {
+		t.Error("expected qa to be unknown before it is configured")
+	}
+
+	if err := ConfigureEnvironments([]EnvironmentConfig{{ID: "qa"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	env, err := flag.Environment()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if env.ID() != "qa" {
+		t.Errorf("expected qa, got %s", env)
+	}
+}
+
+func TestFlagValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		env     ApplicationEnvironment
+		app     Application
+		wantErr string
+	}{
+		{name: "defaults", env: AppEnvDevelopment, app: "shop"},
+		{name: "names", args: []string{"--env", "Production", "--app", " My Shop "}, env: AppEnvProduction, app: "My Shop"},
+		{name: "ID", args: []string{"--env=int"}, env: AppEnvIntegration, app: "shop"},
+		{name: "unknown environment", args: []string{"--env", "staging"}, wantErr: `unknown environment "staging"`},
+		{name: "invalid application", args: []string{"--app", "!!!"}, wantErr: `invalid application name "!!!"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, app := AppEnvDevelopment, Application("shop")
+
+			flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+			flags.Var(&env, "env", "environment")
+			flags.Var(&app, "app", "application")
+
+			if flags.Lookup("env").Value.Type() != "environment" || flags.Lookup("app").Value.Type() != "application" {
+				t.Error("unexpected flag types")
+			}
+
+			err := flags.Parse(tt.args)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if env != tt.env || app != tt.app {
+				t.Errorf("expected %s and %q, got %s and %q", tt.env, tt.app, env, app)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package common
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/tokens"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/workspace"
+)
+
+// Returns the Pulumi workspace options to use a state store, opened under stateURI, and its secrets provider.
+// Backend and secrets are passed to the Pulumi engine only, the environment of the current process is left untouched.
+func GetWorkspaceOptions(store StateStore, stateURI string) ([]auto.LocalWorkspaceOption, error) {
+	return getWorkspaceOptions(store.SecretsProvider(), stateURI)
+}
+
+func getWorkspaceOptions(secretsProvider SecretsProvider, stateURI string) ([]auto.LocalWorkspaceOption, error) {
+	secretsEnv, err := secretsProvider.Env()
+	if err != nil {
+		return nil, err
+	}
+
+	envVars := map[string]string{
+		"PULUMI_BACKEND_URL": stateURI,
+	}
+
+	for k, v := range secretsEnv {
+		envVars[k] = v
+	}
+
+	return []auto.LocalWorkspaceOption{
+		auto.EnvVars(envVars),
+		auto.SecretsProvider(secretsProvider.URL()),
+	}, nil
+}
+
+// Creates a local Pulumi workspace for an inline Go program of the given project.
+func getProjectWorkspace(ctx context.Context, project string, opts ...auto.LocalWorkspaceOption) (auto.Workspace, error) {
+	settings := workspace.Project{
+		Name:    tokens.PackageName(project),
+		Runtime: workspace.NewProjectRuntimeInfo("go", nil),
+	}
+
+	return auto.NewLocalWorkspace(ctx, append(opts, auto.Project(settings))...)
+}
+
+// Splits a stack name as listed by a workspace into project and stack, e.g. "organization/project/stack".
+// Returns the default project for names without a project part.
+func splitStackName(name, defaultProject string) (string, string) {
+	parts := strings.Split(name, "/")
+
+	switch len(parts) {
+	case 3:
+		return parts[1], parts[2]
+	case 2:
+		return defaultProject, parts[1]
+	}
+
+	return defaultProject, name
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"github.com/apex/log"
 	"github.com/apex/log/handlers/text"
+	"sourcesign.de/cloudprism/cmd"
 )
 
 func main() {
@@ -12,5 +13,5 @@ func main() {
 	log.SetLevel(log.DebugLevel)
 
 	// Jump over to the CLI part
-	//_ = cmd.Execute()
+	_ = cmd.Execute()
 }